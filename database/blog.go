@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"myblog/util"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,12 +14,20 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+var (
+	ErrBlogNotExist    = errors.New("blog not exist")
+	ErrVersionConflict = errors.New("blog version conflict")
+)
+
 type Blog struct {
-	Id         int       `gorm:"column:id;primaryKey"`
-	UserId     int       `gorm:"column:user_id"`
-	Title      string    `gorm:"column:title"`
-	Article    string    `gorm:"column:article"`
-	UpdateTime time.Time `gorm:"column:update_time"`
+	Id         int            `gorm:"column:id;primaryKey"`
+	UserId     int            `gorm:"column:user_id"`
+	Title      string         `gorm:"column:title"`
+	Article    string         `gorm:"column:article"`
+	Category   string         `gorm:"column:category;type:varchar(64);not null;default:'';index"`
+	UpdateTime time.Time      `gorm:"column:update_time"`
+	Version    int            `gorm:"column:version;not null;default:1"` // 乐观锁版本号, 标题或正文每次修改加一
+	DeleteTime gorm.DeletedAt `gorm:"column:delete_time;index"`          // 软删除, 非空表示在回收站中
 }
 
 type PublicBlog struct {
@@ -27,20 +37,42 @@ type PublicBlog struct {
 }
 
 type PublicBlogPreview struct {
-	Id         int       `gorm:"column:id"`
-	UserId     int       `gorm:"column:user_id"`
-	UserName   string    `gorm:"column:user_name"`
-	Title      string    `gorm:"column:title"`
-	UpdateTime time.Time `gorm:"column:update_time"`
+	Id          int       `gorm:"column:id"`
+	UserId      int       `gorm:"column:user_id"`
+	UserName    string    `gorm:"column:user_name"`
+	Title       string    `gorm:"column:title"`
+	Category    string    `gorm:"column:category"`
+	Slug        string    `gorm:"column:slug"`
+	UpdateTime  time.Time `gorm:"column:update_time"`
+	PublishTime time.Time `gorm:"column:publish_time"`
+	Tags        []string  `gorm:"-"`
+}
+
+// PublicBlogQuery 公共列表的筛选与分页条件, 空字段表示不筛选
+type PublicBlogQuery struct {
+	PageQuery
+	UserId   int
+	Tag      string
+	Category string
 }
 
 type PublicBlogDetail struct {
-	Id         int       `gorm:"column:id"`
-	UserId     int       `gorm:"column:user_id"`
-	UserName   string    `gorm:"column:user_name"`
-	Title      string    `gorm:"column:title"`
-	Article    string    `gorm:"column:article"`
-	UpdateTime time.Time `gorm:"column:update_time"`
+	Id          int       `gorm:"column:id"`
+	UserId      int       `gorm:"column:user_id"`
+	UserName    string    `gorm:"column:user_name"`
+	Title       string    `gorm:"column:title"`
+	Article     string    `gorm:"column:article"`
+	Category    string    `gorm:"column:category"`
+	Slug        string    `gorm:"column:slug"`
+	UpdateTime  time.Time `gorm:"column:update_time"`
+	PublishTime time.Time `gorm:"column:publish_time"`
+	Tags        []string  `gorm:"-"`
+}
+
+// BlogMeta 与标题正文在同一事务中保存的分类和标签
+type BlogMeta struct {
+	Category *string  // nil 表示不修改分类
+	Tags     []string // nil 表示不修改标签, 空切片表示清空
 }
 
 func (Blog) TableName() string {
@@ -78,58 +110,221 @@ func GetBlogById(id int) *Blog {
 	return blog
 }
 
-func GetBlogByUserId(uid int) []*Blog {
+// GetBlogByUserId 按 id 倒序分页返回用户的博客(只含 id 和 title)
+func GetBlogByUserId(uid int, page *PageQuery) ([]*Blog, *PageInfo, error) {
+	limit := page.limit()
+	cursor, err := page.cursor()
+	if err != nil {
+		return nil, nil, err
+	}
 	db := GetBlogDBConnection()
+
+	var total int64
+	if err := db.Model(&Blog{}).Where("user_id = ?", uid).Count(&total).Error; err != nil {
+		zap.L().Error("count blogs of user failed", zap.Int("user_id", uid), zap.Error(err))
+		return nil, nil, err
+	}
+
+	tx := db.Select("id, title").Where("user_id = ?", uid)
+	switch {
+	case cursor == nil:
+		tx = tx.Order("id DESC")
+	case cursor.Backward:
+		tx = tx.Where("id > ?", cursor.Id).Order("id ASC")
+	default:
+		tx = tx.Where("id < ?", cursor.Id).Order("id DESC")
+	}
+
 	var blogs []*Blog
+	if err := tx.Limit(limit + 1).Find(&blogs).Error; err != nil {
+		zap.L().Error("get blogs of user failed", zap.Int("user_id", uid), zap.Error(err))
+		return nil, nil, err
+	}
 
-	if err := db.Select("id, title").Where("user_id = ?", uid).Find(&blogs).Error; err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			zap.L().Error("get blogs of user failed", zap.Int("user_id", uid), zap.Error(err))
-		}
-		return nil
+	hasMore := len(blogs) > limit
+	if hasMore {
+		blogs = blogs[:limit]
 	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(blogs)
+	}
+	var first, last *pageCursor
+	if len(blogs) > 0 {
+		first = &pageCursor{Id: blogs[0].Id}
+		last = &pageCursor{Id: blogs[len(blogs)-1].Id}
+	}
+	return blogs, buildPageInfo(total, limit, cursor, hasMore, first, last), nil
+}
 
-	return blogs
+// publicBlogPage 列表查询结果, 作为一个整体缓存
+type publicBlogPage struct {
+	Blogs []*PublicBlogPreview
+	Page  *PageInfo
+}
+
+// GetPublicBlogList 按发布时间倒序分页返回公开博客, 游标为 (publish_time, id)。结果经过 Redis 缓存
+func GetPublicBlogList(query *PublicBlogQuery) ([]*PublicBlogPreview, *PageInfo, error) {
+	if query == nil {
+		query = &PublicBlogQuery{}
+	}
+	result, err := readThrough(cachePublicBlogList, publicBlogListScope, queryCacheField(query), func() (*publicBlogPage, error) {
+		blogs, page, err := loadPublicBlogList(query)
+		return &publicBlogPage{Blogs: blogs, Page: page}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Blogs, result.Page, nil
 }
 
-func GetPublicBlogList() []*PublicBlogPreview {
+func loadPublicBlogList(query *PublicBlogQuery) ([]*PublicBlogPreview, *PageInfo, error) {
+	limit := query.limit()
+	cursor, err := query.cursor()
+	if err != nil {
+		return nil, nil, err
+	}
 	ensurePublicBlogTable()
+	ensureBlogSlugTable()
 	db := GetBlogDBConnection()
 
-	var blogs []*PublicBlogPreview
-	err := db.Table("blog b").
-		Select("b.id, b.user_id, u.name AS user_name, b.title, b.update_time").
-		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id").
+	// 发布时间还在未来的博客(例如副本之间存在时钟偏差时)暂不展示
+	tx := db.Table("blog b").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now())
+	if len(query.Tag) > 0 {
+		ensureTagTable()
+		tx = tx.Joins("INNER JOIN blog_tag bt ON bt.blog_id = b.id").
+			Joins("INNER JOIN tag t ON t.id = bt.tag_id AND t.name = ?", strings.ToLower(query.Tag))
+	}
+	if len(query.Category) > 0 {
+		tx = tx.Where("b.category = ?", query.Category)
+	}
+	if query.UserId > 0 {
+		tx = tx.Where("pb.user_id = ?", query.UserId)
+	}
+
+	// 总数不受游标影响, 翻页时保持不变
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		zap.L().Error("count public blog list failed", zap.Error(err))
+		return nil, nil, err
+	}
+
+	tx = tx.Select("b.id, b.user_id, u.name AS user_name, b.title, b.category, s.slug, b.update_time, pb.publish_time").
 		Joins("LEFT JOIN `user` u ON u.id = b.user_id").
-		Order("pb.publish_time DESC").
-		Find(&blogs).Error
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id AND s.is_current = ?", true)
+	switch {
+	case cursor == nil:
+		tx = tx.Order("pb.publish_time DESC, b.id DESC")
+	case cursor.Backward:
+		tx = tx.Where("pb.publish_time > ? OR (pb.publish_time = ? AND b.id > ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("pb.publish_time ASC, b.id ASC")
+	default:
+		tx = tx.Where("pb.publish_time < ? OR (pb.publish_time = ? AND b.id < ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("pb.publish_time DESC, b.id DESC")
+	}
+
+	var blogs []*PublicBlogPreview
+	if err := tx.Limit(limit + 1).Find(&blogs).Error; err != nil {
+		zap.L().Error("get public blog list failed", zap.Error(err))
+		return nil, nil, err
+	}
+
+	hasMore := len(blogs) > limit
+	if hasMore {
+		blogs = blogs[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(blogs)
+	}
+
+	bids := make([]int, 0, len(blogs))
+	for _, blog := range blogs {
+		bids = append(bids, blog.Id)
+	}
+	tags := GetTagsOfBlogs(bids)
+	for _, blog := range blogs {
+		blog.Tags = tags[blog.Id]
+	}
+
+	var first, last *pageCursor
+	if len(blogs) > 0 {
+		first = &pageCursor{Time: blogs[0].PublishTime, Id: blogs[0].Id}
+		last = &pageCursor{Time: blogs[len(blogs)-1].PublishTime, Id: blogs[len(blogs)-1].Id}
+	}
+	return blogs, buildPageInfo(total, limit, cursor, hasMore, first, last), nil
+}
+
+// GetPublicBlogById 返回公开博客的完整内容, 不存在或未公开时返回 nil。结果经过 Redis 缓存
+func GetPublicBlogById(bid int) *PublicBlogDetail {
+	blog, err := readThrough(cachePublicBlog, publicBlogScope(bid), "detail", func() (*PublicBlogDetail, error) {
+		return loadPublicBlogById(bid)
+	})
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			zap.L().Error("get public blog list failed", zap.Error(err))
-		}
 		return nil
 	}
-	return blogs
+	return blog
 }
 
-func GetPublicBlogById(bid int) *PublicBlogDetail {
+// loadPublicBlogById 博客不存在时返回 nil, nil, 以便缓存“不存在”的结果
+func loadPublicBlogById(bid int) (*PublicBlogDetail, error) {
 	ensurePublicBlogTable()
+	ensureBlogSlugTable()
 	db := GetBlogDBConnection()
 
 	blog := &PublicBlogDetail{}
 	err := db.Table("blog b").
-		Select("b.id, b.user_id, u.name AS user_name, b.title, b.article, b.update_time").
-		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id").
+		Select("b.id, b.user_id, u.name AS user_name, b.title, b.article, b.category, s.slug, b.update_time, pb.publish_time").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
 		Joins("LEFT JOIN `user` u ON u.id = b.user_id").
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id AND s.is_current = ?", true).
 		Where("b.id = ?", bid).
 		First(blog).Error
 	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			zap.L().Error("get public blog by id failed", zap.Int("bid", bid), zap.Error(err))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
+		zap.L().Error("get public blog by id failed", zap.Int("bid", bid), zap.Error(err))
+		return nil, err
+	}
+	blog.Tags = GetBlogTags(bid)
+	return blog, nil
+}
+
+// GetPublicBlogsByIds 批量查询公开博客的完整内容, 结果按 bids 的顺序返回, 不存在或未公开的博客被跳过
+func GetPublicBlogsByIds(bids []int) []*PublicBlogDetail {
+	if len(bids) == 0 {
 		return nil
 	}
-	return blog
+	ensurePublicBlogTable()
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	var rows []*PublicBlogDetail
+	err := db.Table("blog b").
+		Select("b.id, b.user_id, u.name AS user_name, b.title, b.article, b.category, s.slug, b.update_time, pb.publish_time").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Joins("LEFT JOIN `user` u ON u.id = b.user_id").
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id AND s.is_current = ?", true).
+		Where("b.id IN ?", bids).
+		Find(&rows).Error
+	if err != nil {
+		zap.L().Error("get public blogs by ids failed", zap.Ints("bids", bids), zap.Error(err))
+		return nil
+	}
+
+	byId := make(map[int]*PublicBlogDetail, len(rows))
+	for _, row := range rows {
+		byId[row.Id] = row
+	}
+	tags := GetTagsOfBlogs(bids)
+	blogs := make([]*PublicBlogDetail, 0, len(rows))
+	for _, bid := range bids {
+		if blog, ok := byId[bid]; ok {
+			blog.Tags = tags[bid]
+			blogs = append(blogs, blog)
+		}
+	}
+	return blogs
 }
 
 func IsBlogPublic(bid int) bool {
@@ -137,7 +332,7 @@ func IsBlogPublic(bid int) bool {
 	db := GetBlogDBConnection()
 
 	var count int64
-	err := db.Model(&PublicBlog{}).Where("blog_id = ?", bid).Count(&count).Error
+	err := db.Model(&PublicBlog{}).Where("blog_id = ? AND publish_time <= ?", bid, time.Now()).Count(&count).Error
 	if err != nil {
 		zap.L().Error("check blog public status failed", zap.Int("bid", bid), zap.Error(err))
 		return false
@@ -145,21 +340,48 @@ func IsBlogPublic(bid int) bool {
 	return count > 0
 }
 
+// GetBlogsPublicStatus 批量查询博客当前是否公开
+func GetBlogsPublicStatus(bids []int) map[int]bool {
+	result := make(map[int]bool, len(bids))
+	if len(bids) == 0 {
+		return result
+	}
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	var publicBids []int
+	err := db.Model(&PublicBlog{}).Where("blog_id IN ? AND publish_time <= ?", bids, time.Now()).Pluck("blog_id", &publicBids).Error
+	if err != nil {
+		zap.L().Error("get blogs public status failed", zap.Ints("bids", bids), zap.Error(err))
+		return result
+	}
+	for _, bid := range publicBids {
+		result[bid] = true
+	}
+	return result
+}
+
 func PublishBlog(bid, uid int) error {
 	if bid <= 0 || uid <= 0 {
 		return fmt.Errorf("invalid blog id or user id")
 	}
 	ensurePublicBlogTable()
 
-	publishTime := time.Now()
+	db := GetBlogDBConnection()
+	if err := publishBlog(db, bid, uid, time.Now()); err != nil {
+		return err
+	}
+	invalidatePublicBlog(bid)
+	return nil
+}
+
+func publishBlog(tx *gorm.DB, bid, uid int, publishTime time.Time) error {
 	publicBlog := &PublicBlog{
 		BlogId:      bid,
 		UserId:      uid,
 		PublishTime: publishTime,
 	}
-
-	db := GetBlogDBConnection()
-	return db.Clauses(clause.OnConflict{
+	return tx.Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "blog_id"}},
 		DoUpdates: clause.Assignments(map[string]any{
 			"user_id":      uid,
@@ -175,10 +397,17 @@ func UnpublishBlog(bid, uid int) error {
 	ensurePublicBlogTable()
 
 	db := GetBlogDBConnection()
-	return db.Where("blog_id = ? AND user_id = ?", bid, uid).Delete(&PublicBlog{}).Error
+	if err := db.Where("blog_id = ? AND user_id = ?", bid, uid).Delete(&PublicBlog{}).Error; err != nil {
+		return err
+	}
+	invalidatePublicBlog(bid)
+	return nil
 }
 
-func UpdateBlog(blog *Blog) error {
+// UpdateBlog 更新标题和正文, 以及 meta 中给出的分类和标签 (meta 可以为 nil)。
+// blog.Version 是客户端编辑时基于的版本, 与库中不一致时返回 ErrVersionConflict。
+// 成功后 blog.Version 和 blog.UpdateTime 更新为新值
+func UpdateBlog(blog *Blog, meta *BlogMeta) error {
 	if blog.Id <= 0 {
 		return fmt.Errorf("could not update blog of id %d", blog.Id)
 	}
@@ -187,14 +416,52 @@ func UpdateBlog(blog *Blog) error {
 		return fmt.Errorf("could not set blog title or article to empty")
 	}
 
+	ensureBlogRevisionTable()
+	ensureBlogSlugTable()
+	ensureTagTable()
+	updateTime := time.Now()
+	updates := map[string]any{
+		"title":       blog.Title,
+		"article":     blog.Article,
+		"update_time": updateTime, // 渲染缓存以 update_time 作为版本
+		"version":     gorm.Expr("version + 1"),
+	}
+	if meta != nil && meta.Category != nil {
+		updates["category"] = NormalizeCategory(*meta.Category)
+	}
 	db := GetBlogDBConnection()
-	return db.Model(&Blog{}).Where("id = ?", blog.Id).Updates(map[string]any{
-		"title":   blog.Title,
-		"article": blog.Article,
-	}).Error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Blog{}).Where("id = ? AND version = ?", blog.Id, blog.Version).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if _, err := assignBlogSlug(tx, blog.Id, blog.Title); err != nil {
+			return err
+		}
+		if meta != nil && meta.Tags != nil {
+			if err := setBlogTags(tx, blog.Id, meta.Tags); err != nil {
+				return err
+			}
+		}
+		return createBlogRevision(tx, blog.Id, blog.UserId, blog.Title, blog.Article, 0, updateTime)
+	})
+	if err != nil {
+		return err
+	}
+	invalidatePublicBlog(blog.Id)
+	blog.Version++
+	blog.UpdateTime = updateTime
+	if category, ok := updates["category"].(string); ok {
+		blog.Category = category
+	}
+	return nil
 }
 
-func CreateBlog(blog *Blog) error {
+// CreateBlog 创建博客, meta 中的分类和标签 (meta 可以为 nil) 在同一事务中写入
+func CreateBlog(blog *Blog, meta *BlogMeta) error {
 	if blog.UserId <= 0 {
 		return fmt.Errorf("invalid user id")
 	}
@@ -204,7 +471,29 @@ func CreateBlog(blog *Blog) error {
 	if blog.UpdateTime.IsZero() {
 		blog.UpdateTime = time.Now()
 	}
+	if blog.Version <= 0 {
+		blog.Version = 1
+	}
+	if meta != nil && meta.Category != nil {
+		blog.Category = NormalizeCategory(*meta.Category)
+	}
 
+	ensureBlogRevisionTable()
+	ensureBlogSlugTable()
+	ensureTagTable()
 	db := GetBlogDBConnection()
-	return db.Create(blog).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(blog).Error; err != nil {
+			return err
+		}
+		if _, err := assignBlogSlug(tx, blog.Id, blog.Title); err != nil {
+			return err
+		}
+		if meta != nil && len(meta.Tags) > 0 {
+			if err := setBlogTags(tx, blog.Id, meta.Tags); err != nil {
+				return err
+			}
+		}
+		return createBlogRevision(tx, blog.Id, blog.UserId, blog.Title, blog.Article, 0, blog.UpdateTime)
+	})
 }
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidBookmark = errors.New("invalid bookmark parameter")
+
+// BlogBookmark 用户收藏的博客。博客取消发布或删除后收藏保留, 只是不再显示, 重新发布后恢复显示
+type BlogBookmark struct {
+	UserId     int       `gorm:"column:user_id;primaryKey"`
+	BlogId     int       `gorm:"column:blog_id;primaryKey;index"`
+	CreateTime time.Time `gorm:"column:create_time;not null"`
+}
+
+func (BlogBookmark) TableName() string {
+	return "blog_bookmark"
+}
+
+// BookmarkedBlog 收藏列表中的一项, BookmarkTime 为收藏时间
+type BookmarkedBlog struct {
+	PublicBlogPreview `gorm:"embedded"`
+	BookmarkTime      time.Time `gorm:"column:bookmark_time"`
+}
+
+var blogBookmarkMigrator sync.Once
+
+func ensureBlogBookmarkTable() {
+	db := GetBlogDBConnection()
+	blogBookmarkMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogBookmark{}); err != nil {
+			zap.L().Error("migrate blog_bookmark failed", zap.Error(err))
+		}
+	})
+}
+
+// AddBookmark 收藏公开博客, 已收藏时不做任何修改
+func AddBookmark(uid int, bid int) error {
+	if uid <= 0 || bid <= 0 {
+		return ErrInvalidBookmark
+	}
+	if !IsBlogPublic(bid) {
+		return ErrPublicBlogNotExist
+	}
+
+	ensureBlogBookmarkTable()
+	db := GetBlogDBConnection()
+	bookmark := &BlogBookmark{UserId: uid, BlogId: bid, CreateTime: time.Now()}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(bookmark).Error
+}
+
+// RemoveBookmark 取消收藏, 不要求博客仍然公开, 未收藏时同样视为成功
+func RemoveBookmark(uid int, bid int) error {
+	if uid <= 0 || bid <= 0 {
+		return ErrInvalidBookmark
+	}
+
+	ensureBlogBookmarkTable()
+	db := GetBlogDBConnection()
+	return db.Where("user_id = ? AND blog_id = ?", uid, bid).Delete(&BlogBookmark{}).Error
+}
+
+// IsBookmarked 用户是否收藏了该博客
+func IsBookmarked(uid int, bid int) (bool, error) {
+	ensureBlogBookmarkTable()
+	db := GetBlogDBConnection()
+
+	var count int64
+	err := db.Model(&BlogBookmark{}).Where("user_id = ? AND blog_id = ?", uid, bid).Count(&count).Error
+	if err != nil {
+		zap.L().Error("get bookmark failed", zap.Int("uid", uid), zap.Int("bid", bid), zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetBookmarkedBlogs 按收藏时间倒序分页返回用户收藏的博客, 游标为 (收藏时间, 博客 id)。
+// 只返回当前公开的博客, 总数也只统计这些博客
+func GetBookmarkedBlogs(uid int, page *PageQuery) ([]*BookmarkedBlog, *PageInfo, error) {
+	limit := page.limit()
+	cursor, err := page.cursor()
+	if err != nil {
+		return nil, nil, err
+	}
+	ensureBlogBookmarkTable()
+	ensurePublicBlogTable()
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	tx := db.Table("blog_bookmark bb").
+		Joins("INNER JOIN blog b ON b.id = bb.blog_id").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Where("bb.user_id = ?", uid)
+
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		zap.L().Error("count bookmarks failed", zap.Int("uid", uid), zap.Error(err))
+		return nil, nil, err
+	}
+
+	tx = tx.Select("b.id, b.user_id, u.name AS user_name, b.title, b.category, s.slug, b.update_time, pb.publish_time, bb.create_time AS bookmark_time").
+		Joins("LEFT JOIN `user` u ON u.id = b.user_id").
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id AND s.is_current = ?", true)
+	switch {
+	case cursor == nil:
+		tx = tx.Order("bb.create_time DESC, b.id DESC")
+	case cursor.Backward:
+		tx = tx.Where("bb.create_time > ? OR (bb.create_time = ? AND b.id > ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("bb.create_time ASC, b.id ASC")
+	default:
+		tx = tx.Where("bb.create_time < ? OR (bb.create_time = ? AND b.id < ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("bb.create_time DESC, b.id DESC")
+	}
+
+	var blogs []*BookmarkedBlog
+	if err := tx.Limit(limit + 1).Find(&blogs).Error; err != nil {
+		zap.L().Error("get bookmarks failed", zap.Int("uid", uid), zap.Error(err))
+		return nil, nil, err
+	}
+
+	hasMore := len(blogs) > limit
+	if hasMore {
+		blogs = blogs[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(blogs)
+	}
+
+	bids := make([]int, 0, len(blogs))
+	for _, blog := range blogs {
+		bids = append(bids, blog.Id)
+	}
+	tags := GetTagsOfBlogs(bids)
+	for _, blog := range blogs {
+		blog.Tags = tags[blog.Id]
+	}
+
+	var first, last *pageCursor
+	if len(blogs) > 0 {
+		first = &pageCursor{Time: blogs[0].BookmarkTime, Id: blogs[0].Id}
+		last = &pageCursor{Time: blogs[len(blogs)-1].BookmarkTime, Id: blogs[len(blogs)-1].Id}
+	}
+	return blogs, buildPageInfo(total, limit, cursor, hasMore, first, last), nil
+}
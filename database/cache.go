@@ -0,0 +1,138 @@
+package database
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CACHE_PREFIX = "blog_cache_"
+	CACHE_EXPIRE = 10 * time.Minute
+)
+
+// 缓存名, 作为指标的 cache 标签
+const (
+	cachePublicBlog         = "public_blog"
+	cachePublicBlogList     = "public_blog_list"
+	cachePublicBlogComments = "public_blog_comments"
+)
+
+var (
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "myblog_cache_requests_total",
+		Help: "Total number of read-through cache lookups, result is hit, miss or error.",
+	}, []string{"cache", "result"})
+
+	cacheGroup singleflight.Group
+)
+
+// 缓存按作用域失效: 每个作用域在 Redis 中有一个代数, 缓存键带上当前代数, 失效时把代数加一,
+// 旧代数下的键不再被读到, 随过期时间自然淘汰。回源查询期间发生失效时结果写入的是旧代数的键,
+// 不会把旧数据写进新代数
+
+func publicBlogScope(bid int) string {
+	return "blog_" + strconv.Itoa(bid)
+}
+
+func publicBlogCommentsScope(bid int) string {
+	return "comments_" + strconv.Itoa(bid)
+}
+
+const publicBlogListScope = "list"
+
+func cacheGenKey(scope string) string {
+	return CACHE_PREFIX + "gen_" + scope
+}
+
+// readThrough 先读 Redis, 未命中时回源查询并写回。同一个键的并发回源通过 singleflight 合并为一次,
+// 避免缓存失效瞬间大量请求同时打到 MySQL; Redis 不可用时直接回源
+func readThrough[T any](cache, scope, field string, load func() (T, error)) (T, error) {
+	client := InitRedisClient()
+	gen, err := client.Get(cacheGenKey(scope)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		cacheRequests.WithLabelValues(cache, "error").Inc()
+		zap.L().Error("get cache generation failed", zap.String("scope", scope), zap.Error(err))
+		return load()
+	}
+	key := fmt.Sprintf("%s%s_%d_%s", CACHE_PREFIX, scope, gen, field)
+
+	value, err := client.Get(key).Bytes()
+	switch {
+	case err == nil:
+		var result T
+		if err := sonic.Unmarshal(value, &result); err == nil {
+			cacheRequests.WithLabelValues(cache, "hit").Inc()
+			return result, nil
+		}
+		zap.L().Error("unmarshal cache failed", zap.String("key", key), zap.Error(err))
+	case !errors.Is(err, redis.Nil):
+		cacheRequests.WithLabelValues(cache, "error").Inc()
+		zap.L().Error("get cache failed", zap.String("key", key), zap.Error(err))
+		return load()
+	}
+	cacheRequests.WithLabelValues(cache, "miss").Inc()
+
+	shared, err, _ := cacheGroup.Do(key, func() (any, error) {
+		result, err := load()
+		if err != nil {
+			return result, err
+		}
+		data, err := sonic.Marshal(result)
+		if err != nil {
+			zap.L().Error("marshal cache failed", zap.String("key", key), zap.Error(err))
+			return result, nil
+		}
+		if err := client.Set(key, data, CACHE_EXPIRE).Err(); err != nil {
+			zap.L().Error("set cache failed", zap.String("key", key), zap.Error(err))
+		}
+		return result, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return shared.(T), nil
+}
+
+// invalidateCache 让作用域下的所有缓存失效, 失败时只记日志, 缓存最迟在 CACHE_EXPIRE 后过期
+func invalidateCache(scopes ...string) {
+	client := InitRedisClient()
+	for _, scope := range scopes {
+		if err := client.Incr(cacheGenKey(scope)).Err(); err != nil {
+			zap.L().Error("invalidate cache failed", zap.String("scope", scope), zap.Error(err))
+		}
+	}
+}
+
+// invalidatePublicBlog 博客内容、标签或公开状态变化, 详情和所有列表页都要失效
+func invalidatePublicBlog(bid int) {
+	invalidateCache(publicBlogScope(bid), publicBlogListScope)
+}
+
+func invalidatePublicBlogComments(bid int) {
+	invalidateCache(publicBlogCommentsScope(bid))
+}
+
+// queryCacheField 把列表查询条件压缩为缓存键的一部分
+func queryCacheField(query *PublicBlogQuery) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%d|%s|%d|%q|%q", query.limit(), query.Cursor, query.UserId, query.Tag, query.Category)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func commentQueryCacheField(query *PublicBlogCommentQuery) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%d|%s|%s", query.limit(), query.Cursor, query.Sort)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,24 +19,66 @@ var (
 	ErrPublicBlogNotExist    = errors.New("public blog not exist")
 	ErrCommentNotExist       = errors.New("comment not exist")
 	ErrCommentNoPermission   = errors.New("no permission to delete comment")
+	ErrCommentEditDenied     = errors.New("no permission to edit comment")
+	ErrCommentEditExpired    = errors.New("comment edit window expired")
 	ErrInvalidDeleteComment  = errors.New("invalid delete comment parameter")
+	ErrInvalidEditComment    = errors.New("invalid edit comment parameter")
+	ErrInvalidParentComment  = errors.New("invalid parent comment")
+	ErrInvalidCommentSort    = errors.New("invalid comment sort")
+	ErrCommentsClosed        = errors.New("comments are closed")
 )
 
+// 评论列表的排序方式, 按顶层评论的发表时间
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+)
+
+// 评论的审核状态, 只有 CommentStatusApproved 的评论公开显示
+const (
+	CommentStatusApproved = "approved"
+	CommentStatusPending  = "pending" // 博客开启审核时新评论等待作者通过
+	CommentStatusHidden   = "hidden"  // 博客作者隐藏的评论
+)
+
+// BlogComment 博客评论, ParentId 为 0 表示直接评论博客, 否则是对该评论的回复。
+// 删除仍有回复的评论时只清空内容并标记 Deleted, 保留在原位置以免回复失去上下文
 type BlogComment struct {
-	Id         int       `gorm:"column:id;primaryKey"`
-	BlogId     int       `gorm:"column:blog_id;not null;index"`
-	UserId     int       `gorm:"column:user_id;not null;index"`
-	Content    string    `gorm:"column:content;not null;type:text"`
-	CreateTime time.Time `gorm:"column:create_time"`
+	Id          int        `gorm:"column:id;primaryKey"`
+	BlogId      int        `gorm:"column:blog_id;not null;index"`
+	ParentId    int        `gorm:"column:parent_id;not null;default:0;index"`
+	UserId      int        `gorm:"column:user_id;not null;index"`
+	Content     string     `gorm:"column:content;not null;type:text"`
+	Deleted     bool       `gorm:"column:deleted;not null;default:false"`
+	Status      string     `gorm:"column:status;not null;size:16;default:'approved'"`
+	CreateTime  time.Time  `gorm:"column:create_time"`
+	UpdateTime  *time.Time `gorm:"column:update_time"`                         // 最近一次修改, 包括编辑和删除为占位
+	EditTime    *time.Time `gorm:"column:edit_time"`                           // 作者最近一次编辑内容的时间, 未编辑过为空
+	BlogTrashed bool       `gorm:"column:blog_trashed;not null;default:false"` // 所属博客在回收站中时隐藏
 }
 
 type PublicBlogCommentItem struct {
-	Id         int       `gorm:"column:id"`
-	BlogId     int       `gorm:"column:blog_id"`
-	UserId     int       `gorm:"column:user_id"`
-	UserName   string    `gorm:"column:user_name"`
-	Content    string    `gorm:"column:content"`
-	CreateTime time.Time `gorm:"column:create_time"`
+	Id         int        `gorm:"column:id"`
+	BlogId     int        `gorm:"column:blog_id"`
+	ParentId   int        `gorm:"column:parent_id"`
+	UserId     int        `gorm:"column:user_id"`
+	UserName   string     `gorm:"column:user_name"`
+	Content    string     `gorm:"column:content"`
+	Deleted    bool       `gorm:"column:deleted"`
+	Status     string     `gorm:"column:status"`
+	CreateTime time.Time  `gorm:"column:create_time"`
+	EditTime   *time.Time `gorm:"column:edit_time"`
+}
+
+// PublicBlogCommentQuery 评论列表的分页与排序条件。分页的单位是顶层评论, 每条顶层评论连同全部回复一起返回
+type PublicBlogCommentQuery struct {
+	PageQuery
+	Sort string // CommentSortNewest 或 CommentSortOldest, 为空时按 CommentSortNewest
+}
+
+type publicBlogCommentPage struct {
+	Comments []*PublicBlogCommentItem
+	Page     *PageInfo
 }
 
 func (BlogComment) TableName() string {
@@ -53,55 +96,191 @@ func ensureBlogCommentTable() {
 	})
 }
 
-func CreatePublicBlogComment(bid int, uid int, content string) error {
+// CreatePublicBlogComment 发表评论, 返回新插入的评论
+func CreatePublicBlogComment(bid int, uid int, content string) (*PublicBlogCommentItem, error) {
+	return ReplyPublicBlogComment(bid, uid, 0, content)
+}
+
+// ReplyPublicBlogComment 回复博客下的评论, parentId 为 0 时等同于 CreatePublicBlogComment。
+// 被回复的评论必须属于同一篇博客、未被删除且已公开显示。博客关闭评论时返回 ErrCommentsClosed,
+// 开启审核时除博客作者外的评论为 CommentStatusPending
+func ReplyPublicBlogComment(bid int, uid int, parentId int, content string) (*PublicBlogCommentItem, error) {
 	if bid <= 0 || uid <= 0 {
-		return fmt.Errorf("invalid blog id or user id")
+		return nil, fmt.Errorf("invalid blog id or user id")
+	}
+	if parentId < 0 {
+		return nil, ErrInvalidParentComment
 	}
 	trimmedContent := strings.TrimSpace(content)
 	if len(trimmedContent) == 0 || len([]rune(trimmedContent)) > maxCommentContentLength {
-		return ErrInvalidCommentContent
+		return nil, ErrInvalidCommentContent
 	}
 	if !IsBlogPublic(bid) {
-		return ErrPublicBlogNotExist
+		return nil, ErrPublicBlogNotExist
+	}
+	status := CommentStatusApproved
+	switch GetBlogCommentMode(bid) {
+	case CommentModeClosed:
+		return nil, ErrCommentsClosed
+	case CommentModeApproval:
+		if blog := GetBlogById(bid); blog == nil || blog.UserId != uid {
+			status = CommentStatusPending
+		}
 	}
 
 	ensureBlogCommentTable()
+	db := GetBlogDBConnection()
+	if parentId > 0 {
+		var count int64
+		err := db.Model(&BlogComment{}).
+			Where("id = ? AND blog_id = ? AND deleted = ? AND status = ?", parentId, bid, false, CommentStatusApproved).
+			Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrInvalidParentComment
+		}
+	}
+
 	comment := &BlogComment{
 		BlogId:     bid,
+		ParentId:   parentId,
 		UserId:     uid,
 		Content:    trimmedContent,
+		Status:     status,
 		CreateTime: time.Now(),
 	}
+	if err := db.Create(comment).Error; err != nil {
+		return nil, err
+	}
+	invalidatePublicBlogComments(bid)
 
-	db := GetBlogDBConnection()
-	return db.Create(comment).Error
+	item := &PublicBlogCommentItem{
+		Id:         comment.Id,
+		BlogId:     comment.BlogId,
+		ParentId:   comment.ParentId,
+		UserId:     comment.UserId,
+		Content:    comment.Content,
+		Status:     comment.Status,
+		CreateTime: comment.CreateTime,
+	}
+	if user := GetUserById(uid); user != nil {
+		item.UserName = user.Name
+	}
+	return item, nil
 }
 
-func GetPublicBlogComments(bid int) []*PublicBlogCommentItem {
-	if bid <= 0 {
-		return nil
+// GetPublicBlogComments 按顶层评论分页返回博客的评论: 先是本页的顶层评论, 按 query.Sort 排列,
+// 然后是它们的全部回复, 按发表时间先后排列, 由调用方按 ParentId 组织成树。
+// 已删除但仍有回复的评论也在结果中, Deleted 为 true 且内容为空。
+// 只返回公开显示的评论, 等待审核或被隐藏的评论连同其回复都不显示。结果经过 Redis 缓存
+func GetPublicBlogComments(bid int, query *PublicBlogCommentQuery) ([]*PublicBlogCommentItem, *PageInfo, error) {
+	if query == nil {
+		query = &PublicBlogCommentQuery{}
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = CommentSortNewest
 	}
+	if query.Sort != CommentSortNewest && query.Sort != CommentSortOldest {
+		return nil, nil, ErrInvalidCommentSort
+	}
+	result, err := readThrough(cachePublicBlogComments, publicBlogCommentsScope(bid), commentQueryCacheField(query), func() (*publicBlogCommentPage, error) {
+		comments, page, err := loadPublicBlogComments(bid, query)
+		return &publicBlogCommentPage{Comments: comments, Page: page}, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Comments, result.Page, nil
+}
 
+const publicBlogCommentColumns = "bc.id, bc.blog_id, bc.parent_id, bc.user_id, u.name AS user_name, bc.content, bc.deleted, bc.status, bc.create_time, bc.edit_time"
+
+func loadPublicBlogComments(bid int, query *PublicBlogCommentQuery) ([]*PublicBlogCommentItem, *PageInfo, error) {
+	limit := query.limit()
+	cursor, err := query.cursor()
+	if err != nil {
+		return nil, nil, err
+	}
 	ensureBlogCommentTable()
 	db := GetBlogDBConnection()
 
-	var comments []*PublicBlogCommentItem
-	err := db.Table("blog_comment bc").
-		Select("bc.id, bc.blog_id, bc.user_id, u.name AS user_name, bc.content, bc.create_time").
-		Joins("LEFT JOIN `user` u ON u.id = bc.user_id").
-		Where("bc.blog_id = ?", bid).
-		Order("bc.create_time DESC").
-		Find(&comments).Error
-	if err != nil {
-		if err != gorm.ErrRecordNotFound {
-			zap.L().Error("get public blog comments failed", zap.Int("bid", bid), zap.Error(err))
+	tx := db.Table("blog_comment bc").
+		Where("bc.blog_id = ? AND bc.blog_trashed = ? AND bc.parent_id = ? AND bc.status = ?", bid, false, 0, CommentStatusApproved)
+
+	// 总数是顶层评论的条数, 不受游标影响
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		zap.L().Error("count public blog comments failed", zap.Int("bid", bid), zap.Error(err))
+		return nil, nil, err
+	}
+
+	// 向前翻页时按相反的顺序查询, 查到后再反转
+	ascending := query.Sort == CommentSortOldest
+	if cursor != nil && cursor.Backward {
+		ascending = !ascending
+	}
+	tx = tx.Select(publicBlogCommentColumns).Joins("LEFT JOIN `user` u ON u.id = bc.user_id")
+	if ascending {
+		if cursor != nil {
+			tx = tx.Where("bc.create_time > ? OR (bc.create_time = ? AND bc.id > ?)", cursor.Time, cursor.Time, cursor.Id)
 		}
-		return nil
+		tx = tx.Order("bc.create_time ASC, bc.id ASC")
+	} else {
+		if cursor != nil {
+			tx = tx.Where("bc.create_time < ? OR (bc.create_time = ? AND bc.id < ?)", cursor.Time, cursor.Time, cursor.Id)
+		}
+		tx = tx.Order("bc.create_time DESC, bc.id DESC")
+	}
+
+	var comments []*PublicBlogCommentItem
+	if err := tx.Limit(limit + 1).Find(&comments).Error; err != nil {
+		zap.L().Error("get public blog comments failed", zap.Int("bid", bid), zap.Error(err))
+		return nil, nil, err
+	}
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
 	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(comments)
+	}
+
+	var first, last *pageCursor
+	if len(comments) > 0 {
+		first = &pageCursor{Time: comments[0].CreateTime, Id: comments[0].Id}
+		last = &pageCursor{Time: comments[len(comments)-1].CreateTime, Id: comments[len(comments)-1].Id}
+	}
+	page := buildPageInfo(total, limit, cursor, hasMore, first, last)
 
-	return comments
+	// 逐层查询回复, 每层一次查询
+	parents := make([]int, 0, len(comments))
+	for _, comment := range comments {
+		parents = append(parents, comment.Id)
+	}
+	for len(parents) > 0 {
+		var replies []*PublicBlogCommentItem
+		err := db.Table("blog_comment bc").
+			Select(publicBlogCommentColumns).
+			Joins("LEFT JOIN `user` u ON u.id = bc.user_id").
+			Where("bc.parent_id IN ? AND bc.blog_trashed = ? AND bc.status = ?", parents, false, CommentStatusApproved).
+			Order("bc.create_time ASC, bc.id ASC").
+			Find(&replies).Error
+		if err != nil {
+			zap.L().Error("get public blog comment replies failed", zap.Int("bid", bid), zap.Error(err))
+			return nil, nil, err
+		}
+		comments = append(comments, replies...)
+		parents = parents[:0]
+		for _, reply := range replies {
+			parents = append(parents, reply.Id)
+		}
+	}
+	return comments, page, nil
 }
 
+// DeletePublicBlogComment 删除评论, 评论作者和博客作者都可以删除
 func DeletePublicBlogComment(bid int, cid int, uid int) error {
 	if bid <= 0 || cid <= 0 || uid <= 0 {
 		return ErrInvalidDeleteComment
@@ -111,10 +290,11 @@ func DeletePublicBlogComment(bid int, cid int, uid int) error {
 	}
 
 	ensureBlogCommentTable()
+	ensureBlogCommentHistoryTable()
 	db := GetBlogDBConnection()
 
 	comment := &BlogComment{}
-	err := db.Where("id = ? AND blog_id = ?", cid, bid).First(comment).Error
+	err := db.Where("id = ? AND blog_id = ? AND deleted = ?", cid, bid, false).First(comment).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return ErrCommentNotExist
@@ -123,16 +303,66 @@ func DeletePublicBlogComment(bid int, cid int, uid int) error {
 	}
 
 	if comment.UserId != uid {
-		return ErrCommentNoPermission
+		if blog := GetBlogById(bid); blog == nil || blog.UserId != uid {
+			return ErrCommentNoPermission
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return removeComment(tx, comment)
+	})
+	if err != nil {
+		return err
+	}
+	invalidatePublicBlogComments(bid)
+	return nil
+}
+
+// removeComment 删除评论: 有回复时改为占位, 没有回复时真正删除,
+// 并继续清理因此不再有回复的已删除的上级评论。删除前的内容记入编辑历史, 历史不随评论删除
+func removeComment(tx *gorm.DB, comment *BlogComment) error {
+	if !comment.Deleted {
+		if err := createCommentHistory(tx, comment, time.Now()); err != nil {
+			return err
+		}
+	}
+	var replies int64
+	if err := tx.Model(&BlogComment{}).Where("parent_id = ?", comment.Id).Count(&replies).Error; err != nil {
+		return err
+	}
+	if replies > 0 {
+		if comment.Deleted {
+			return nil // 已是占位, 仍有其他回复
+		}
+		result := tx.Model(&BlogComment{}).
+			Where("id = ? AND deleted = ?", comment.Id, false).
+			Updates(map[string]any{"deleted": true, "content": "", "update_time": time.Now(), "edit_time": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCommentNotExist
+		}
+		return nil
 	}
 
-	result := db.Where("id = ? AND blog_id = ? AND user_id = ?", cid, bid, uid).Delete(&BlogComment{})
+	result := tx.Where("id = ?", comment.Id).Delete(&BlogComment{})
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
 		return ErrCommentNotExist
 	}
-
-	return nil
+	if comment.ParentId == 0 {
+		return nil
+	}
+	parent := &BlogComment{}
+	err := tx.Where("id = ? AND deleted = ?", comment.ParentId, true).Take(parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return removeComment(tx, parent)
 }
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlogCommentHistory 评论被编辑或删除前的内容, 每次编辑或删除追加一条, CreateTime 为被替换的时间。
+// 评论删除后历史仍然保留, 博客彻底删除时才一并清理
+type BlogCommentHistory struct {
+	Id         int       `gorm:"column:id;primaryKey"`
+	CommentId  int       `gorm:"column:comment_id;not null;index"`
+	BlogId     int       `gorm:"column:blog_id;not null;default:0;index"`
+	UserId     int       `gorm:"column:user_id;not null;default:0"` // 评论作者, 评论删除后仍可查到
+	Content    string    `gorm:"column:content;not null;type:text"`
+	CreateTime time.Time `gorm:"column:create_time"`
+}
+
+func (BlogCommentHistory) TableName() string {
+	return "blog_comment_history"
+}
+
+var blogCommentHistoryMigrator sync.Once
+
+func ensureBlogCommentHistoryTable() {
+	db := GetBlogDBConnection()
+	blogCommentHistoryMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogCommentHistory{}); err != nil {
+			zap.L().Error("migrate blog_comment_history failed", zap.Error(err))
+		}
+	})
+}
+
+// EditPublicBlogComment 评论作者修改评论内容, 原内容写入历史表, 返回修改后的评论。
+// editWindow 大于 0 时只能在发表后的这段时间内修改; 内容没有变化时不做任何修改。
+// 博客关闭评论时不能修改, 开启审核时修改后的评论需要重新审核
+func EditPublicBlogComment(bid int, cid int, uid int, content string, editWindow time.Duration) (*PublicBlogCommentItem, error) {
+	if bid <= 0 || cid <= 0 || uid <= 0 {
+		return nil, ErrInvalidEditComment
+	}
+	trimmedContent := strings.TrimSpace(content)
+	if len(trimmedContent) == 0 || len([]rune(trimmedContent)) > maxCommentContentLength {
+		return nil, ErrInvalidCommentContent
+	}
+	if !IsBlogPublic(bid) {
+		return nil, ErrPublicBlogNotExist
+	}
+	requireApproval := false
+	switch GetBlogCommentMode(bid) {
+	case CommentModeClosed:
+		return nil, ErrCommentsClosed
+	case CommentModeApproval:
+		blog := GetBlogById(bid)
+		requireApproval = blog == nil || blog.UserId != uid
+	}
+
+	ensureBlogCommentTable()
+	ensureBlogCommentHistoryTable()
+	db := GetBlogDBConnection()
+
+	comment := &BlogComment{}
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND blog_id = ? AND deleted = ?", cid, bid, false).
+			First(comment).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCommentNotExist
+			}
+			return err
+		}
+		if comment.UserId != uid {
+			return ErrCommentEditDenied
+		}
+		now := time.Now()
+		if editWindow > 0 && now.Sub(comment.CreateTime) > editWindow {
+			return ErrCommentEditExpired
+		}
+		if comment.Content == trimmedContent {
+			return nil
+		}
+
+		if err := createCommentHistory(tx, comment, now); err != nil {
+			return err
+		}
+		updates := map[string]any{"content": trimmedContent, "edit_time": now, "update_time": now}
+		if requireApproval && comment.Status == CommentStatusApproved {
+			updates["status"] = CommentStatusPending
+			comment.Status = CommentStatusPending
+		}
+		if err := tx.Model(&BlogComment{}).Where("id = ?", comment.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+		comment.Content = trimmedContent
+		comment.EditTime = &now
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		invalidatePublicBlogComments(bid)
+	}
+
+	item := &PublicBlogCommentItem{
+		Id:         comment.Id,
+		BlogId:     comment.BlogId,
+		ParentId:   comment.ParentId,
+		UserId:     comment.UserId,
+		Content:    comment.Content,
+		Status:     comment.Status,
+		CreateTime: comment.CreateTime,
+		EditTime:   comment.EditTime,
+	}
+	if user := GetUserById(uid); user != nil {
+		item.UserName = user.Name
+	}
+	return item, nil
+}
+
+// createCommentHistory 在事务 tx 中记录评论当前的内容
+func createCommentHistory(tx *gorm.DB, comment *BlogComment, now time.Time) error {
+	history := &BlogCommentHistory{
+		CommentId:  comment.Id,
+		BlogId:     comment.BlogId,
+		UserId:     comment.UserId,
+		Content:    comment.Content,
+		CreateTime: now,
+	}
+	return tx.Create(history).Error
+}
+
+// GetCommentHistory 按时间倒序返回评论被编辑或删除前的各个版本, 评论已删除时同样可以查询
+func GetCommentHistory(cid int) ([]*BlogCommentHistory, error) {
+	ensureBlogCommentHistoryTable()
+	db := GetBlogDBConnection()
+
+	var histories []*BlogCommentHistory
+	err := db.Where("comment_id = ?", cid).Order("create_time DESC, id DESC").Find(&histories).Error
+	if err != nil {
+		zap.L().Error("get comment history failed", zap.Int("cid", cid), zap.Error(err))
+		return nil, err
+	}
+	return histories, nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidCommentMode   = errors.New("invalid comment mode")
+	ErrInvalidCommentStatus = errors.New("invalid comment status")
+)
+
+// 博客的评论模式
+const (
+	CommentModeOpen     = "open"     // 新评论直接公开显示
+	CommentModeApproval = "approval" // 新评论需要博客作者审核通过后显示
+	CommentModeClosed   = "closed"   // 不能发表或修改评论, 已有评论照常显示
+)
+
+// BlogCommentSetting 博客的评论设置, 没有记录的博客为 CommentModeOpen
+type BlogCommentSetting struct {
+	BlogId     int       `gorm:"column:blog_id;primaryKey"`
+	Mode       string    `gorm:"column:mode;not null;size:16"`
+	UpdateTime time.Time `gorm:"column:update_time"`
+}
+
+func (BlogCommentSetting) TableName() string {
+	return "blog_comment_setting"
+}
+
+var blogCommentSettingMigrator sync.Once
+
+func ensureBlogCommentSettingTable() {
+	db := GetBlogDBConnection()
+	blogCommentSettingMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogCommentSetting{}); err != nil {
+			zap.L().Error("migrate blog_comment_setting failed", zap.Error(err))
+		}
+	})
+}
+
+func IsValidCommentMode(mode string) bool {
+	return mode == CommentModeOpen || mode == CommentModeApproval || mode == CommentModeClosed
+}
+
+// GetBlogCommentMode 博客的评论模式, 查询失败时按 CommentModeOpen 处理
+func GetBlogCommentMode(bid int) string {
+	ensureBlogCommentSettingTable()
+	db := GetBlogDBConnection()
+
+	setting := &BlogCommentSetting{}
+	err := db.Where("blog_id = ?", bid).Take(setting).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zap.L().Error("get blog comment mode failed", zap.Int("bid", bid), zap.Error(err))
+		}
+		return CommentModeOpen
+	}
+	return setting.Mode
+}
+
+// SetBlogCommentMode 修改博客的评论模式, 只影响之后发表的评论, 已在等待审核的评论保持不变
+func SetBlogCommentMode(bid int, mode string) error {
+	if bid <= 0 || !IsValidCommentMode(mode) {
+		return ErrInvalidCommentMode
+	}
+
+	ensureBlogCommentSettingTable()
+	db := GetBlogDBConnection()
+	setting := &BlogCommentSetting{BlogId: bid, Mode: mode, UpdateTime: time.Now()}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(setting).Error
+}
+
+// SetCommentStatus 博客作者审核通过 (CommentStatusApproved) 或隐藏 (CommentStatusHidden) 评论,
+// 隐藏的评论可以再次通过。调用方负责校验博客作者
+func SetCommentStatus(bid int, cid int, status string) error {
+	if bid <= 0 || cid <= 0 {
+		return ErrCommentNotExist
+	}
+	if status != CommentStatusApproved && status != CommentStatusHidden {
+		return ErrInvalidCommentStatus
+	}
+
+	ensureBlogCommentTable()
+	db := GetBlogDBConnection()
+	result := db.Model(&BlogComment{}).
+		Where("id = ? AND blog_id = ? AND deleted = ?", cid, bid, false).
+		Updates(map[string]any{"status": status, "update_time": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCommentNotExist
+	}
+	invalidatePublicBlogComments(bid)
+	return nil
+}
+
+// GetBlogCommentsByStatus 供博客作者审核, 按发表时间倒序分页平铺返回博客的评论, 不含已删除的占位;
+// status 为空时返回全部状态的评论。游标为 (发表时间, 评论 id)
+func GetBlogCommentsByStatus(bid int, status string, page *PageQuery) ([]*PublicBlogCommentItem, *PageInfo, error) {
+	if len(status) > 0 && status != CommentStatusApproved && status != CommentStatusPending && status != CommentStatusHidden {
+		return nil, nil, ErrInvalidCommentStatus
+	}
+	limit := page.limit()
+	cursor, err := page.cursor()
+	if err != nil {
+		return nil, nil, err
+	}
+	ensureBlogCommentTable()
+	db := GetBlogDBConnection()
+
+	tx := db.Table("blog_comment bc").Where("bc.blog_id = ? AND bc.deleted = ?", bid, false)
+	if len(status) > 0 {
+		tx = tx.Where("bc.status = ?", status)
+	}
+
+	var total int64
+	if err := tx.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		zap.L().Error("count blog comments failed", zap.Int("bid", bid), zap.String("status", status), zap.Error(err))
+		return nil, nil, err
+	}
+
+	tx = tx.Select(publicBlogCommentColumns).Joins("LEFT JOIN `user` u ON u.id = bc.user_id")
+	switch {
+	case cursor == nil:
+		tx = tx.Order("bc.create_time DESC, bc.id DESC")
+	case cursor.Backward:
+		tx = tx.Where("bc.create_time > ? OR (bc.create_time = ? AND bc.id > ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("bc.create_time ASC, bc.id ASC")
+	default:
+		tx = tx.Where("bc.create_time < ? OR (bc.create_time = ? AND bc.id < ?)", cursor.Time, cursor.Time, cursor.Id).
+			Order("bc.create_time DESC, bc.id DESC")
+	}
+
+	var comments []*PublicBlogCommentItem
+	if err := tx.Limit(limit + 1).Find(&comments).Error; err != nil {
+		zap.L().Error("get blog comments failed", zap.Int("bid", bid), zap.String("status", status), zap.Error(err))
+		return nil, nil, err
+	}
+	hasMore := len(comments) > limit
+	if hasMore {
+		comments = comments[:limit]
+	}
+	if cursor != nil && cursor.Backward {
+		slices.Reverse(comments)
+	}
+
+	var first, last *pageCursor
+	if len(comments) > 0 {
+		first = &pageCursor{Time: comments[0].CreateTime, Id: comments[0].Id}
+		last = &pageCursor{Time: comments[len(comments)-1].CreateTime, Id: comments[len(comments)-1].Id}
+	}
+	return comments, buildPageInfo(total, limit, cursor, hasMore, first, last), nil
+}
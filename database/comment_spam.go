@@ -0,0 +1,56 @@
+package database
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 评论反垃圾用到的 Redis 键: 限流按固定时间窗口计数, 键中带窗口序号, 窗口结束后自然过期;
+// 重复内容按用户登记最近发表过的内容摘要
+const (
+	COMMENT_SPAM_PREFIX = "blog_comment_spam_"
+)
+
+func commentRateKey(scope string, id string, window time.Duration, now time.Time) string {
+	slot := now.UnixNano() / int64(window)
+	return COMMENT_SPAM_PREFIX + "rate_" + scope + "_" + id + "_" + strconv.FormatInt(slot, 10)
+}
+
+// commentDigest 忽略大小写和空白差异后的内容摘要, 只改动空白或大小写的内容仍视为重复
+func commentDigest(content string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(content), " "))
+	hash := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+func commentDuplicateKey(uid int, content string) string {
+	return COMMENT_SPAM_PREFIX + "dup_" + strconv.Itoa(uid) + "_" + commentDigest(content)
+}
+
+// IncrCommentRate 在 scope (如 user、ip) 下为 id 累计一次发表评论的尝试,
+// 返回当前窗口内的累计次数和窗口的剩余时间
+func IncrCommentRate(scope string, id string, window time.Duration, now time.Time) (int64, time.Duration, error) {
+	key := commentRateKey(scope, id, window, now)
+	pipe := InitRedisClient().TxPipeline()
+	count := pipe.Incr(key)
+	pipe.Expire(key, window)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, 0, err
+	}
+	remaining := window - time.Duration(now.UnixNano()%int64(window))
+	return count.Val(), remaining, nil
+}
+
+// ClaimComment 原子地登记用户将要发表的内容, window 内已登记过相同内容时返回 false, 即重复。
+// 同时发表相同内容的请求只有一个能登记成功; 评论没有发表成功时调用 ReleaseComment 撤销登记
+func ClaimComment(uid int, content string, window time.Duration) (bool, error) {
+	return InitRedisClient().SetNX(commentDuplicateKey(uid, content), 1, window).Result()
+}
+
+// ReleaseComment 撤销 ClaimComment 的登记, 之后可以再次发表相同的内容
+func ReleaseComment(uid int, content string) error {
+	return InitRedisClient().Del(commentDuplicateKey(uid, content)).Err()
+}
@@ -95,7 +95,9 @@ func InitBlogDBConnection() *gorm.DB { //初始化链接myblog数据库的变量
 	return blog_mysql
 }
 
-// Redis
+// InitRedisClient 返回 Redis 客户端, 不会返回 nil。
+// 首次连接失败时只记日志不 panic: 客户端在每次执行命令时自动重连, Redis 不可用期间命令返回错误,
+// 由调用方决定降级 (缓存直接回源、反垃圾检查跳过等), Redis 恢复后无需重启即可继续使用
 func InitRedisClient() *redis.Client {
 	blog_redis_once.Do(func() {
 		config := util.CreateConfig("redis")
@@ -106,10 +108,10 @@ func InitRedisClient() *redis.Client {
 		})
 		result, err := client.Ping().Result()
 		if err != nil {
-			zap.L().Panic("failed to connect to redis", zap.Error(err))
-			panic(err)
+			zap.L().Error("failed to connect to redis, will retry on use", zap.Error(err))
+		} else {
+			zap.L().Info("connected to redis", zap.String("result", result))
 		}
-		zap.L().Info("connected to redis", zap.String("result", result))
 		blog_redis = client
 	})
 	return blog_redis
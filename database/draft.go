@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+const (
+	DRAFT_PREFIX = "blog_draft_"
+	DRAFT_EXPIRE = 7 * 24 * time.Hour
+)
+
+// BlogDraft 编辑器自动保存的草稿, 按用户+博客存放在 Redis 中
+type BlogDraft struct {
+	Title    string    `json:"title"`
+	Article  string    `json:"article"`
+	SaveTime time.Time `json:"save_time"`
+}
+
+func draftKey(uid, bid int) string {
+	return fmt.Sprintf("%s%d_%d", DRAFT_PREFIX, uid, bid)
+}
+
+func SaveDraft(uid, bid int, draft *BlogDraft) error {
+	if draft.SaveTime.IsZero() {
+		draft.SaveTime = time.Now()
+	}
+	value, err := sonic.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	client := InitRedisClient()
+	return client.Set(draftKey(uid, bid), value, DRAFT_EXPIRE).Err()
+}
+
+// GetDraft 返回未过期的草稿, 不存在时返回 nil
+func GetDraft(uid, bid int) *BlogDraft {
+	client := InitRedisClient()
+	value, err := client.Get(draftKey(uid, bid)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			zap.L().Error("get draft failed", zap.Int("uid", uid), zap.Int("bid", bid), zap.Error(err))
+		}
+		return nil
+	}
+	draft := &BlogDraft{}
+	if err := sonic.Unmarshal(value, draft); err != nil {
+		zap.L().Error("unmarshal draft failed", zap.Int("uid", uid), zap.Int("bid", bid), zap.Error(err))
+		return nil
+	}
+	return draft
+}
+
+func DeleteDraft(uid, bid int) {
+	client := InitRedisClient()
+	if err := client.Del(draftKey(uid, bid)).Err(); err != nil {
+		zap.L().Error("delete draft failed", zap.Int("uid", uid), zap.Int("bid", bid), zap.Error(err))
+	}
+}
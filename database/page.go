@@ -0,0 +1,98 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// PageQuery 键集分页参数, Cursor 为空表示第一页
+type PageQuery struct {
+	Limit  int
+	Cursor string
+}
+
+// PageInfo 分页结果, Next/Prev 为空表示没有下一页/上一页
+type PageInfo struct {
+	Total int64  `json:"total"`
+	Limit int    `json:"limit"`
+	Next  string `json:"next_cursor"`
+	Prev  string `json:"prev_cursor"`
+}
+
+// pageCursor 指向某一行的排序键 (Time, Id), Backward 表示从该行向前翻页
+type pageCursor struct {
+	Time     time.Time
+	Id       int
+	Backward bool
+}
+
+func (page *PageQuery) limit() int {
+	if page == nil || page.Limit <= 0 {
+		return DefaultPageSize
+	}
+	return min(page.Limit, MaxPageSize)
+}
+
+func (page *PageQuery) cursor() (*pageCursor, error) {
+	if page == nil || len(page.Cursor) == 0 {
+		return nil, nil
+	}
+	return decodeCursor(page.Cursor)
+}
+
+func encodeCursor(cursor *pageCursor) string {
+	direction := "n"
+	if cursor.Backward {
+		direction = "p"
+	}
+	raw := fmt.Sprintf("%s.%d.%d", direction, cursor.Time.UnixNano(), cursor.Id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(value string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var direction string
+	var nano int64
+	var id int
+	// %1s 只读取一个字符作为方向
+	if _, err := fmt.Sscanf(string(raw), "%1s.%d.%d", &direction, &nano, &id); err != nil || id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	if direction != "n" && direction != "p" {
+		return nil, ErrInvalidCursor
+	}
+	return &pageCursor{Time: time.Unix(0, nano), Id: id, Backward: direction == "p"}, nil
+}
+
+// buildPageInfo 根据本次查询的方向和是否多取到一行, 生成上一页/下一页游标。
+// first/last 是本页(已按展示顺序排列)第一行和最后一行的排序键
+func buildPageInfo(total int64, limit int, cursor *pageCursor, hasMore bool, first, last *pageCursor) *PageInfo {
+	info := &PageInfo{Total: total, Limit: limit}
+	if first == nil || last == nil {
+		return info
+	}
+	backward := cursor != nil && cursor.Backward
+	hasNext := (!backward && hasMore) || backward
+	hasPrev := (backward && hasMore) || (!backward && cursor != nil)
+	if hasNext {
+		info.Next = encodeCursor(&pageCursor{Time: last.Time, Id: last.Id})
+	}
+	if hasPrev {
+		info.Prev = encodeCursor(&pageCursor{Time: first.Time, Id: first.Id, Backward: true})
+	}
+	return info
+}
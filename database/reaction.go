@@ -0,0 +1,164 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+// Reaction 一种表态, Kind 存入数据库, Emoji 用于展示
+type Reaction struct {
+	Kind  string `json:"kind"`
+	Emoji string `json:"emoji"`
+}
+
+// Reactions 支持的表态, 按展示顺序排列, 其中 like 即点赞
+var Reactions = []Reaction{
+	{Kind: "like", Emoji: "👍"},
+	{Kind: "love", Emoji: "❤️"},
+	{Kind: "laugh", Emoji: "😄"},
+	{Kind: "hooray", Emoji: "🎉"},
+	{Kind: "wow", Emoji: "😮"},
+	{Kind: "sad", Emoji: "😢"},
+}
+
+func IsValidReaction(kind string) bool {
+	for _, reaction := range Reactions {
+		if reaction.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// BlogReaction 一个用户对一篇博客的一种表态, 同一用户可以同时有多种表态
+type BlogReaction struct {
+	BlogId     int       `gorm:"column:blog_id;primaryKey"`
+	UserId     int       `gorm:"column:user_id;primaryKey"`
+	Kind       string    `gorm:"column:kind;primaryKey;size:16"`
+	CreateTime time.Time `gorm:"column:create_time;index"`
+}
+
+func (BlogReaction) TableName() string {
+	return "blog_reaction"
+}
+
+// ReactionCount 一种表态的人数
+type ReactionCount struct {
+	Reaction
+	Count int64 `json:"count"`
+}
+
+// BlogReactionSummary 博客的表态统计, Mine 是当前用户已有的表态
+type BlogReactionSummary struct {
+	Counts []*ReactionCount `json:"counts"`
+	Mine   []string         `json:"mine"`
+}
+
+var blogReactionMigrator sync.Once
+
+func ensureBlogReactionTable() {
+	db := GetBlogDBConnection()
+	blogReactionMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogReaction{}); err != nil {
+			zap.L().Error("migrate blog_reaction failed", zap.Error(err))
+		}
+	})
+}
+
+// AddBlogReaction 添加表态, 已存在时不做任何修改
+func AddBlogReaction(bid int, uid int, kind string) error {
+	if bid <= 0 || uid <= 0 || !IsValidReaction(kind) {
+		return ErrInvalidReaction
+	}
+	if !IsBlogPublic(bid) {
+		return ErrPublicBlogNotExist
+	}
+
+	ensureBlogReactionTable()
+	db := GetBlogDBConnection()
+	reaction := &BlogReaction{BlogId: bid, UserId: uid, Kind: kind, CreateTime: time.Now()}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error
+}
+
+// RemoveBlogReaction 取消表态, 不存在时同样视为成功
+func RemoveBlogReaction(bid int, uid int, kind string) error {
+	if bid <= 0 || uid <= 0 || !IsValidReaction(kind) {
+		return ErrInvalidReaction
+	}
+	if !IsBlogPublic(bid) {
+		return ErrPublicBlogNotExist
+	}
+
+	ensureBlogReactionTable()
+	db := GetBlogDBConnection()
+	return db.Where("blog_id = ? AND user_id = ? AND kind = ?", bid, uid, kind).Delete(&BlogReaction{}).Error
+}
+
+// GetBlogReactionSummary 博客每种表态的人数, uid 大于 0 时同时返回该用户的表态
+func GetBlogReactionSummary(bid int, uid int) (*BlogReactionSummary, error) {
+	summary := &BlogReactionSummary{Counts: GetBlogReactionCounts([]int{bid})[bid], Mine: []string{}}
+	if summary.Counts == nil {
+		summary.Counts = []*ReactionCount{}
+	}
+	if uid <= 0 {
+		return summary, nil
+	}
+
+	db := GetBlogDBConnection()
+	err := db.Model(&BlogReaction{}).
+		Where("blog_id = ? AND user_id = ?", bid, uid).
+		Pluck("kind", &summary.Mine).Error
+	if err != nil {
+		zap.L().Error("get user reactions failed", zap.Int("bid", bid), zap.Int("uid", uid), zap.Error(err))
+		return nil, err
+	}
+	return summary, nil
+}
+
+// GetBlogReactionCounts 批量查询博客的表态人数, 按 Reactions 的顺序排列且不含人数为 0 的表态;
+// 没有表态的博客不在结果中
+func GetBlogReactionCounts(bids []int) map[int][]*ReactionCount {
+	result := make(map[int][]*ReactionCount, len(bids))
+	if len(bids) == 0 {
+		return result
+	}
+	ensureBlogReactionTable()
+	db := GetBlogDBConnection()
+
+	var rows []struct {
+		BlogId int    `gorm:"column:blog_id"`
+		Kind   string `gorm:"column:kind"`
+		Count  int64  `gorm:"column:count"`
+	}
+	err := db.Model(&BlogReaction{}).
+		Select("blog_id, kind, COUNT(*) AS count").
+		Where("blog_id IN ?", bids).
+		Group("blog_id, kind").
+		Find(&rows).Error
+	if err != nil {
+		zap.L().Error("get blog reaction counts failed", zap.Ints("bids", bids), zap.Error(err))
+		return result
+	}
+
+	counts := make(map[int]map[string]int64, len(bids))
+	for _, row := range rows {
+		if counts[row.BlogId] == nil {
+			counts[row.BlogId] = make(map[string]int64)
+		}
+		counts[row.BlogId][row.Kind] = row.Count
+	}
+	for bid, kinds := range counts {
+		for _, reaction := range Reactions {
+			if count := kinds[reaction.Kind]; count > 0 {
+				result[bid] = append(result[bid], &ReactionCount{Reaction: reaction, Count: count})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRevisionNotExist = errors.New("revision not exist")
+)
+
+// BlogRevision 博客的历史版本, 每次创建/更新/恢复都会追加一条
+type BlogRevision struct {
+	Id          int       `gorm:"column:id;primaryKey"`
+	BlogId      int       `gorm:"column:blog_id;not null;index"`
+	UserId      int       `gorm:"column:user_id;not null"`
+	Title       string    `gorm:"column:title"`
+	Article     string    `gorm:"column:article;type:longtext"`
+	RestoreFrom int       `gorm:"column:restore_from;not null;default:0"` // 由哪个历史版本恢复而来, 0 表示普通保存
+	CreateTime  time.Time `gorm:"column:create_time"`
+}
+
+func (BlogRevision) TableName() string {
+	return "blog_revision"
+}
+
+var blogRevisionMigrator sync.Once
+
+func ensureBlogRevisionTable() {
+	db := GetBlogDBConnection()
+	blogRevisionMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogRevision{}); err != nil {
+			zap.L().Error("migrate blog_revision failed", zap.Error(err))
+		}
+	})
+}
+
+func createBlogRevision(tx *gorm.DB, bid, uid int, title, article string, restoreFrom int, createTime time.Time) error {
+	return tx.Create(&BlogRevision{
+		BlogId:      bid,
+		UserId:      uid,
+		Title:       title,
+		Article:     article,
+		RestoreFrom: restoreFrom,
+		CreateTime:  createTime,
+	}).Error
+}
+
+// GetBlogRevisions 按时间倒序返回博客的历史版本, 不包含正文
+func GetBlogRevisions(bid int) []*BlogRevision {
+	if bid <= 0 {
+		return nil
+	}
+	ensureBlogRevisionTable()
+	db := GetBlogDBConnection()
+
+	var revisions []*BlogRevision
+	err := db.Select("id, blog_id, user_id, title, restore_from, create_time").
+		Where("blog_id = ?", bid).
+		Order("id DESC").
+		Find(&revisions).Error
+	if err != nil {
+		zap.L().Error("get blog revisions failed", zap.Int("bid", bid), zap.Error(err))
+		return nil
+	}
+	return revisions
+}
+
+func GetBlogRevisionById(bid, rid int) *BlogRevision {
+	if bid <= 0 || rid <= 0 {
+		return nil
+	}
+	ensureBlogRevisionTable()
+	db := GetBlogDBConnection()
+
+	revision := &BlogRevision{}
+	err := db.Where("id = ? AND blog_id = ?", rid, bid).First(revision).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zap.L().Error("get blog revision failed", zap.Int("bid", bid), zap.Int("rid", rid), zap.Error(err))
+		}
+		return nil
+	}
+	return revision
+}
+
+// RestoreBlogRevision 把博客内容恢复为某个历史版本, 恢复操作本身也记录为一个新版本
+func RestoreBlogRevision(bid, rid, uid int) error {
+	ensureBlogRevisionTable()
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		revision := &BlogRevision{}
+		err := tx.Where("id = ? AND blog_id = ?", rid, bid).First(revision).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRevisionNotExist
+			}
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(&Blog{}).Where("id = ?", bid).Updates(map[string]any{
+			"title":       revision.Title,
+			"article":     revision.Article,
+			"update_time": now,
+			"version":     gorm.Expr("version + 1"), // 恢复也是一次修改, 打开中的编辑器提交时会冲突
+		}).Error
+		if err != nil {
+			return err
+		}
+		if _, err := assignBlogSlug(tx, bid, revision.Title); err != nil {
+			return err
+		}
+		return createBlogRevision(tx, bid, uid, revision.Title, revision.Article, revision.Id, now)
+	})
+	if err != nil {
+		return err
+	}
+	invalidatePublicBlog(bid)
+	return nil
+}
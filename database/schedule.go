@@ -0,0 +1,141 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlogSchedule 博客的定时发布/定时取消发布计划, 对应动作执行后字段被置空
+type BlogSchedule struct {
+	BlogId      int        `gorm:"column:blog_id;primaryKey"`
+	UserId      int        `gorm:"column:user_id;not null"`
+	PublishAt   *time.Time `gorm:"column:publish_at;index"`
+	UnpublishAt *time.Time `gorm:"column:unpublish_at;index"`
+}
+
+func (BlogSchedule) TableName() string {
+	return "blog_schedule"
+}
+
+var blogScheduleMigrator sync.Once
+
+func ensureBlogScheduleTable() {
+	db := GetBlogDBConnection()
+	blogScheduleMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogSchedule{}); err != nil {
+			zap.L().Error("migrate blog_schedule failed", zap.Error(err))
+		}
+	})
+}
+
+// SetBlogSchedule 保存博客的发布计划, 覆盖之前的计划; 两个时间都为空时删除计划
+func SetBlogSchedule(bid, uid int, publishAt, unpublishAt *time.Time) error {
+	ensureBlogScheduleTable()
+	db := GetBlogDBConnection()
+
+	if publishAt == nil && unpublishAt == nil {
+		return db.Where("blog_id = ?", bid).Delete(&BlogSchedule{}).Error
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "blog_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "publish_at", "unpublish_at"}),
+	}).Create(&BlogSchedule{
+		BlogId:      bid,
+		UserId:      uid,
+		PublishAt:   publishAt,
+		UnpublishAt: unpublishAt,
+	}).Error
+}
+
+func GetBlogSchedule(bid int) *BlogSchedule {
+	ensureBlogScheduleTable()
+	db := GetBlogDBConnection()
+
+	schedule := &BlogSchedule{}
+	err := db.Where("blog_id = ?", bid).First(schedule).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zap.L().Error("get blog schedule failed", zap.Int("bid", bid), zap.Error(err))
+		}
+		return nil
+	}
+	return schedule
+}
+
+func DeleteBlogSchedule(bid int) error {
+	ensureBlogScheduleTable()
+	db := GetBlogDBConnection()
+	return db.Where("blog_id = ?", bid).Delete(&BlogSchedule{}).Error
+}
+
+// RunDueSchedules 执行所有到期的发布/取消发布计划, 返回执行的动作数。
+// 每个动作先用条件 UPDATE 把计划时间置空来“认领”, 与实际的发布操作处于同一事务中,
+// 多个副本同时扫描时只有认领成功(影响行数为 1)的副本会执行, 不会重复触发
+func RunDueSchedules(now time.Time) (int, error) {
+	ensureBlogScheduleTable()
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	var schedules []*BlogSchedule
+	err := db.Where("publish_at <= ? OR unpublish_at <= ?", now, now).Find(&schedules).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, schedule := range schedules {
+		if schedule.PublishAt != nil && !schedule.PublishAt.After(now) {
+			fired, err := runScheduledAction(schedule.BlogId, "publish_at", *schedule.PublishAt, func(tx *gorm.DB) error {
+				return publishBlog(tx, schedule.BlogId, schedule.UserId, *schedule.PublishAt)
+			})
+			if err != nil {
+				zap.L().Error("scheduled publish failed", zap.Int("bid", schedule.BlogId), zap.Error(err))
+			} else if fired {
+				count++
+			}
+		}
+		// 同一轮中先发布后取消, 两个时间都已过期时最终状态为未公开
+		if schedule.UnpublishAt != nil && !schedule.UnpublishAt.After(now) {
+			fired, err := runScheduledAction(schedule.BlogId, "unpublish_at", *schedule.UnpublishAt, func(tx *gorm.DB) error {
+				return tx.Where("blog_id = ?", schedule.BlogId).Delete(&PublicBlog{}).Error
+			})
+			if err != nil {
+				zap.L().Error("scheduled unpublish failed", zap.Int("bid", schedule.BlogId), zap.Error(err))
+			} else if fired {
+				count++
+			}
+		}
+	}
+
+	// 两个动作都已完成的计划没有保留的必要
+	if err := db.Where("publish_at IS NULL AND unpublish_at IS NULL").Delete(&BlogSchedule{}).Error; err != nil {
+		zap.L().Error("clean finished schedules failed", zap.Error(err))
+	}
+	return count, nil
+}
+
+func runScheduledAction(bid int, column string, at time.Time, action func(tx *gorm.DB) error) (bool, error) {
+	fired := false
+	err := GetBlogDBConnection().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&BlogSchedule{}).
+			Where("blog_id = ? AND "+column+" = ?", bid, at).
+			Update(column, nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // 已被其他副本执行或计划已被修改
+		}
+		fired = true
+		return action(tx)
+	})
+	if fired && err == nil {
+		invalidatePublicBlog(bid)
+	}
+	return fired && err == nil, err
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxSearchResults      = 50
+	fulltextIndexAll      = "ft_blog_title_article"
+	fulltextIndexTitle    = "ft_blog_title"
+	searchTitleBoostScore = 2 // 标题命中的权重高于正文
+	ngramTokenSize        = 2 // 与 MySQL 默认的 ngram_token_size 一致, 更短的词无法通过全文索引命中
+)
+
+type PublicBlogSearchResult struct {
+	Id         int       `gorm:"column:id"`
+	UserId     int       `gorm:"column:user_id"`
+	UserName   string    `gorm:"column:user_name"`
+	Title      string    `gorm:"column:title"`
+	Article    string    `gorm:"column:article"`
+	UpdateTime time.Time `gorm:"column:update_time"`
+	Score      float64   `gorm:"column:score"`
+}
+
+// fulltextReady 全文索引已经建好, 之前的搜索使用 LIKE 查询
+var fulltextReady atomic.Bool
+
+// EnsureFulltextIndex 为 blog 建立 ngram 全文索引, ngram 分词对中文同样有效, 已存在的索引跳过。
+// 建索引会重建整张表, 在启动时由后台任务调用, 不在搜索请求中执行; 失败(例如数据库不支持 ngram)时
+// 返回错误, 搜索继续使用 LIKE 查询, 可以再次调用重试
+func EnsureFulltextIndex() error {
+	if fulltextReady.Load() {
+		return nil
+	}
+	db := GetBlogDBConnection()
+	migrator := db.Migrator()
+	indexes := map[string]string{
+		fulltextIndexAll:   "title, article",
+		fulltextIndexTitle: "title",
+	}
+	for name, columns := range indexes {
+		if migrator.HasIndex(&Blog{}, name) {
+			continue
+		}
+		sql := "ALTER TABLE blog ADD FULLTEXT INDEX " + name + " (" + columns + ") WITH PARSER ngram"
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("create fulltext index %s: %w", name, err)
+		}
+	}
+	fulltextReady.Store(true)
+	return nil
+}
+
+// SearchPublicBlogs 在公开博客的标题和正文中搜索 query, 按相关度倒序返回最多 maxSearchResults 条。
+// 全文索引还没建好, 或单个汉字这样短于 ngram 分词长度的查询使用 LIKE 匹配
+func SearchPublicBlogs(query string) ([]*PublicBlogSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if len(query) == 0 {
+		return nil, nil
+	}
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	tx := db.Table("blog b").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Joins("LEFT JOIN `user` u ON u.id = b.user_id")
+	if !shorterThanNgram(query) && fulltextReady.Load() {
+		tx = tx.Select("b.id, b.user_id, u.name AS user_name, b.title, b.article, b.update_time, "+
+			"MATCH(b.title) AGAINST(? IN NATURAL LANGUAGE MODE) * ? + MATCH(b.title, b.article) AGAINST(? IN NATURAL LANGUAGE MODE) AS score",
+			query, searchTitleBoostScore, query).
+			Where("MATCH(b.title, b.article) AGAINST(? IN NATURAL LANGUAGE MODE)", query)
+	} else {
+		like := "%" + escapeLike(query) + "%"
+		tx = tx.Select("b.id, b.user_id, u.name AS user_name, b.title, b.article, b.update_time, "+
+			"(b.title LIKE ?) * ? + (b.article LIKE ?) AS score", like, searchTitleBoostScore, like).
+			Where("b.title LIKE ? OR b.article LIKE ?", like, like)
+	}
+
+	var results []*PublicBlogSearchResult
+	err := tx.Order("score DESC, pb.publish_time DESC").Limit(maxSearchResults).Find(&results).Error
+	if err != nil {
+		zap.L().Error("search public blogs failed", zap.String("query", query), zap.Error(err))
+		return nil, err
+	}
+	return results, nil
+}
+
+// shorterThanNgram query 中的每个词都短于 ngram 分词长度时, 全文索引查不到任何结果, 改用 LIKE 查询
+func shorterThanNgram(query string) bool {
+	for _, word := range strings.Fields(query) {
+		if utf8.RuneCountInString(word) >= ngramTokenSize {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,210 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"myblog/util"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const maxSitemapEntries = 50000 // sitemap 协议单个文件的上限
+
+var (
+	ErrSlugNotExist = errors.New("slug not exist")
+)
+
+// BlogSlug 博客的 URL 别名。标题修改后旧别名保留(IsCurrent=false)用于跳转, 别名全局唯一且不会被其他博客复用
+type BlogSlug struct {
+	Slug       string    `gorm:"column:slug;type:varchar(80);primaryKey"`
+	BlogId     int       `gorm:"column:blog_id;not null;index"`
+	IsCurrent  bool      `gorm:"column:is_current;not null"`
+	CreateTime time.Time `gorm:"column:create_time"`
+}
+
+type SitemapEntry struct {
+	Id         int       `gorm:"column:id"`
+	Slug       string    `gorm:"column:slug"`
+	UpdateTime time.Time `gorm:"column:update_time"`
+}
+
+func (BlogSlug) TableName() string {
+	return "blog_slug"
+}
+
+var blogSlugMigrator sync.Once
+
+func ensureBlogSlugTable() {
+	db := GetBlogDBConnection()
+	blogSlugMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogSlug{}); err != nil {
+			zap.L().Error("migrate blog_slug failed", zap.Error(err))
+		}
+	})
+}
+
+// BackfillBlogSlugs 为引入别名之前创建的博客补上别名, 返回补上的篇数。
+// 回收站中的博客跳过, 恢复时再补; 每篇一个事务, 单篇失败不影响其他博客
+func BackfillBlogSlugs() (int, error) {
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	var blogs []*Blog
+	err := db.Table("blog b").
+		Select("b.id, b.title").
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id").
+		Where("s.blog_id IS NULL AND b.delete_time IS NULL").
+		Find(&blogs).Error
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, blog := range blogs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return ensureBlogHasSlug(tx, blog.Id, blog.Title)
+		})
+		if err != nil {
+			zap.L().Error("backfill blog slug failed", zap.Int("bid", blog.Id), zap.Error(err))
+			continue
+		}
+		// 列表和详情缓存中的链接仍是旧的, 需要失效
+		invalidatePublicBlog(blog.Id)
+		count++
+	}
+	return count, nil
+}
+
+// ensureBlogHasSlug 博客还没有任何别名时按标题设置一个, 已有别名时不做修改
+func ensureBlogHasSlug(tx *gorm.DB, bid int, title string) error {
+	var count int64
+	if err := tx.Model(&BlogSlug{}).Where("blog_id = ?", bid).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := assignBlogSlug(tx, bid, title)
+	return err
+}
+
+// maxSlugAttempts 并发创建同名博客时, 插入别名遇到唯一键冲突最多重试的次数
+const maxSlugAttempts = 10
+
+// assignBlogSlug 根据标题为博客设置当前别名并返回。
+// 依次尝试 base、base-2、base-3…, 跳过被其他博客占用的别名; 命中本博客用过的别名时直接复用,
+// 因此标题不变或改回旧标题都不会产生新的别名。
+// 读取已占用的别名时不加锁, 其他事务同时插入同一个别名时唯一键冲突, 改用下一个后缀重试
+func assignBlogSlug(tx *gorm.DB, bid int, title string) (string, error) {
+	base := util.Slugify(title)
+	var rows []*BlogSlug
+	err := tx.Where("slug = ? OR slug LIKE ?", base, escapeLike(base)+"-%").Find(&rows).Error
+	if err != nil {
+		return "", err
+	}
+	taken := make(map[string]*BlogSlug, len(rows))
+	for _, row := range rows {
+		taken[row.Slug] = row
+	}
+
+	slug := base
+	next := 2
+	for attempt := 1; ; attempt++ {
+		for taken[slug] != nil && taken[slug].BlogId != bid {
+			slug = fmt.Sprintf("%s-%d", base, next)
+			next++
+		}
+		if row := taken[slug]; row != nil && row.IsCurrent {
+			return slug, nil
+		}
+
+		if err := tx.Model(&BlogSlug{}).Where("blog_id = ? AND is_current = ?", bid, true).Update("is_current", false).Error; err != nil {
+			return "", err
+		}
+		if taken[slug] != nil {
+			return slug, tx.Model(&BlogSlug{}).Where("slug = ?", slug).Update("is_current", true).Error
+		}
+		// MySQL 中唯一键冲突只回滚这一条语句, 事务可以继续
+		err := tx.Create(&BlogSlug{Slug: slug, BlogId: bid, IsCurrent: true, CreateTime: time.Now()}).Error
+		if err == nil || !isDuplicateKey(tx, err) || attempt == maxSlugAttempts {
+			return slug, err
+		}
+		taken[slug] = &BlogSlug{Slug: slug}
+	}
+}
+
+// isDuplicateKey 是否为违反主键或唯一索引的错误
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// GetBlogSlug 返回博客当前的别名, 没有时返回空串
+func GetBlogSlug(bid int) string {
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	var slugs []string
+	err := db.Model(&BlogSlug{}).Where("blog_id = ? AND is_current = ?", bid, true).Limit(1).Pluck("slug", &slugs).Error
+	if err != nil {
+		zap.L().Error("get blog slug failed", zap.Int("bid", bid), zap.Error(err))
+		return ""
+	}
+	if len(slugs) == 0 {
+		return ""
+	}
+	return slugs[0]
+}
+
+// ResolveSlug 查找别名对应的博客, 返回该博客当前的别名; 与传入的 slug 不同说明是旧别名
+func ResolveSlug(slug string) (int, string, error) {
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	slug = strings.ToLower(slug)
+	row := &BlogSlug{}
+	if err := db.Where("slug = ?", slug).First(row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, "", ErrSlugNotExist
+		}
+		return 0, "", err
+	}
+	if row.IsCurrent {
+		return row.BlogId, row.Slug, nil
+	}
+
+	current := &BlogSlug{}
+	if err := db.Where("blog_id = ? AND is_current = ?", row.BlogId, true).First(current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, "", ErrSlugNotExist
+		}
+		return 0, "", err
+	}
+	return current.BlogId, current.Slug, nil
+}
+
+// GetSitemapEntries 返回所有已发布博客的别名与更新时间, 按发布时间倒序
+func GetSitemapEntries() []*SitemapEntry {
+	ensurePublicBlogTable()
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	var entries []*SitemapEntry
+	err := db.Table("blog b").
+		Select("b.id, s.slug, b.update_time").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Joins("LEFT JOIN blog_slug s ON s.blog_id = b.id AND s.is_current = ?", true).
+		Order("pb.publish_time DESC, b.id DESC").
+		Limit(maxSitemapEntries).
+		Find(&entries).Error
+	if err != nil {
+		zap.L().Error("get sitemap entries failed", zap.Error(err))
+		return nil
+	}
+	return entries
+}
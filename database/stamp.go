@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ContentStamp 判断公开内容是否变化所需的摘要, 只查索引和聚合值, 比查询并渲染完整内容便宜得多。
+// 供 HTTP 条件请求生成 ETag 和 Last-Modified
+type ContentStamp struct {
+	Count        int64     // 条数, 删除或取消发布时变化
+	Version      int       // 博客版本, 仅单篇博客有值
+	LastModified time.Time // 相关时间戳中最新的一个
+}
+
+type stampRow struct {
+	Count       int64        `gorm:"column:count"`
+	Version     int          `gorm:"column:version"`
+	UpdateTime  sql.NullTime `gorm:"column:update_time"`
+	PublishTime sql.NullTime `gorm:"column:publish_time"`
+}
+
+func (row *stampRow) stamp() *ContentStamp {
+	stamp := &ContentStamp{Count: row.Count, Version: row.Version, LastModified: row.UpdateTime.Time}
+	if row.PublishTime.Time.After(stamp.LastModified) {
+		stamp.LastModified = row.PublishTime.Time
+	}
+	return stamp
+}
+
+// GetPublicBlogStamp 单篇公开博客的摘要, 由版本、更新时间和发布时间构成; 博客未公开时返回 ErrPublicBlogNotExist
+func GetPublicBlogStamp(bid int) (*ContentStamp, error) {
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	row := &stampRow{}
+	err := db.Table("blog b").
+		Select("1 AS count, b.version, b.update_time, pb.publish_time").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Where("b.id = ?", bid).
+		Take(row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPublicBlogNotExist
+		}
+		zap.L().Error("get public blog stamp failed", zap.Int("bid", bid), zap.Error(err))
+		return nil, err
+	}
+	return row.stamp(), nil
+}
+
+// GetPublicBlogListStamp 全部公开博客的摘要。列表页的筛选、分页以及标签云都只依赖这些博客,
+// 因此各个列表页共用同一个摘要
+func GetPublicBlogListStamp() (*ContentStamp, error) {
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	row := &stampRow{}
+	err := db.Table("blog b").
+		Select("COUNT(*) AS count, MAX(b.update_time) AS update_time, MAX(pb.publish_time) AS publish_time").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Take(row).Error
+	if err != nil {
+		zap.L().Error("get public blog list stamp failed", zap.Error(err))
+		return nil, err
+	}
+	return row.stamp(), nil
+}
+
+// GetPublicBlogCommentsStamp 博客评论列表的摘要, 由评论数和最新的评论或修改时间构成
+func GetPublicBlogCommentsStamp(bid int) (*ContentStamp, error) {
+	ensureBlogCommentTable()
+	db := GetBlogDBConnection()
+
+	row := &stampRow{}
+	err := db.Model(&BlogComment{}).
+		Select("COUNT(*) AS count, MAX(COALESCE(update_time, create_time)) AS update_time").
+		Where("blog_id = ? AND blog_trashed = ?", bid, false).
+		Take(row).Error
+	if err != nil {
+		zap.L().Error("get public blog comments stamp failed", zap.Int("bid", bid), zap.Error(err))
+		return nil, err
+	}
+	return row.stamp(), nil
+}
+
+// GetBlogReactionStamp 全部表态的摘要, 由表态数和最新表态时间构成, 列表页展示表态人数时参与 ETag 的计算
+func GetBlogReactionStamp() (*ContentStamp, error) {
+	ensureBlogReactionTable()
+	db := GetBlogDBConnection()
+
+	row := &stampRow{}
+	err := db.Model(&BlogReaction{}).
+		Select("COUNT(*) AS count, MAX(create_time) AS update_time").
+		Take(row).Error
+	if err != nil {
+		zap.L().Error("get blog reaction stamp failed", zap.Error(err))
+		return nil, err
+	}
+	return row.stamp(), nil
+}
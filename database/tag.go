@@ -0,0 +1,184 @@
+package database
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxTagLength      = 32
+	maxTagsPerBlog    = 10
+	maxCategoryLength = 64
+)
+
+type Tag struct {
+	Id   int    `gorm:"column:id;primaryKey"`
+	Name string `gorm:"column:name;type:varchar(32);not null;uniqueIndex"`
+}
+
+// BlogTag 博客与标签的多对多关系
+type BlogTag struct {
+	BlogId int `gorm:"column:blog_id;primaryKey"`
+	TagId  int `gorm:"column:tag_id;primaryKey;index"`
+}
+
+type TagCount struct {
+	Name  string `gorm:"column:name"`
+	Count int    `gorm:"column:count"`
+}
+
+func (Tag) TableName() string {
+	return "tag"
+}
+
+func (BlogTag) TableName() string {
+	return "blog_tag"
+}
+
+var tagMigrator sync.Once
+
+func ensureTagTable() {
+	db := GetBlogDBConnection()
+	tagMigrator.Do(func() {
+		if err := db.AutoMigrate(&Tag{}, &BlogTag{}); err != nil {
+			zap.L().Error("migrate tag failed", zap.Error(err))
+		}
+	})
+}
+
+// ParseTags 把 "go, 后端，Go" 这样的输入拆分为去重后的小写标签列表
+func ParseTags(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '；'
+	})
+	tags := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		tag := strings.ToLower(strings.TrimSpace(field))
+		if len(tag) == 0 || len([]rune(tag)) > maxTagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == maxTagsPerBlog {
+			break
+		}
+	}
+	return tags
+}
+
+// NormalizeCategory 去掉首尾空白并截断过长的分类名
+func NormalizeCategory(category string) string {
+	category = strings.TrimSpace(category)
+	if runes := []rune(category); len(runes) > maxCategoryLength {
+		category = string(runes[:maxCategoryLength])
+	}
+	return category
+}
+
+// setBlogTags 在事务 tx 中用 tags 整体替换博客的标签, 调用方负责 ensureTagTable 和清理缓存
+func setBlogTags(tx *gorm.DB, bid int, tags []string) error {
+	if err := tx.Where("blog_id = ?", bid).Delete(&BlogTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]*Tag, 0, len(tags))
+	for _, name := range tags {
+		rows = append(rows, &Tag{Name: name})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+
+	var tagIds []int
+	if err := tx.Model(&Tag{}).Where("name IN ?", tags).Pluck("id", &tagIds).Error; err != nil {
+		return err
+	}
+	relations := make([]*BlogTag, 0, len(tagIds))
+	for _, tid := range tagIds {
+		relations = append(relations, &BlogTag{BlogId: bid, TagId: tid})
+	}
+	return tx.Create(&relations).Error
+}
+
+func GetBlogTags(bid int) []string {
+	return GetTagsOfBlogs([]int{bid})[bid]
+}
+
+// GetTagsOfBlogs 批量查询多篇博客的标签, 避免列表页逐条查询
+func GetTagsOfBlogs(bids []int) map[int][]string {
+	result := make(map[int][]string, len(bids))
+	if len(bids) == 0 {
+		return result
+	}
+	ensureTagTable()
+	db := GetBlogDBConnection()
+
+	var rows []struct {
+		BlogId int    `gorm:"column:blog_id"`
+		Name   string `gorm:"column:name"`
+	}
+	err := db.Table("blog_tag bt").
+		Select("bt.blog_id, t.name").
+		Joins("INNER JOIN tag t ON t.id = bt.tag_id").
+		Where("bt.blog_id IN ?", bids).
+		Order("t.name").
+		Find(&rows).Error
+	if err != nil {
+		zap.L().Error("get tags of blogs failed", zap.Ints("bids", bids), zap.Error(err))
+		return result
+	}
+	for _, row := range rows {
+		result[row.BlogId] = append(result[row.BlogId], row.Name)
+	}
+	return result
+}
+
+// GetTagCloud 统计每个标签下公开博客的数量, 按数量倒序
+func GetTagCloud() []*TagCount {
+	ensureTagTable()
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	var tags []*TagCount
+	err := db.Table("tag t").
+		Select("t.name, COUNT(*) AS count").
+		Joins("INNER JOIN blog_tag bt ON bt.tag_id = t.id").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = bt.blog_id AND pb.publish_time <= ?", time.Now()).
+		Group("t.id, t.name").
+		Order("count DESC, t.name").
+		Find(&tags).Error
+	if err != nil {
+		zap.L().Error("get tag cloud failed", zap.Error(err))
+		return nil
+	}
+	return tags
+}
+
+// GetCategoryCounts 统计每个分类下公开博客的数量, 按数量倒序
+func GetCategoryCounts() []*TagCount {
+	ensurePublicBlogTable()
+	db := GetBlogDBConnection()
+
+	var categories []*TagCount
+	err := db.Table("blog b").
+		Select("b.category AS name, COUNT(*) AS count").
+		Joins("INNER JOIN public_blog pb ON pb.blog_id = b.id AND pb.publish_time <= ?", time.Now()).
+		Where("b.category <> ''").
+		Group("b.category").
+		Order("count DESC, b.category").
+		Find(&categories).Error
+	if err != nil {
+		zap.L().Error("get category counts failed", zap.Error(err))
+		return nil
+	}
+	return categories
+}
@@ -17,7 +17,9 @@ func TestGetBlogById(t *testing.T) {
 
 func TestGetBlogByUserId(t *testing.T) {
 	uid := 1
-	blogs := database.GetBlogByUserId(uid)
+	blogs, page, err := database.GetBlogByUserId(uid, nil)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, page.Total, int64(len(blogs)))
 	assert.NotNil(t, blogs, "Expected blogs for user ID %d but got nil", uid)
 	assert.True(t, len(blogs) > 0, "Expected at least one blog for user ID %d but got %d", uid, len(blogs))
 	for _, blog := range blogs {
@@ -27,6 +29,45 @@ func TestGetBlogByUserId(t *testing.T) {
 }
 
 func TestUpdateBlog(t *testing.T) {
-	blog := database.Blog{Id: 1, Title: "双十一", Article: "双十一来临喜洋洋，购物狂欢乐无边。电商盛宴满眼芳，心愿成真喜笑颜。"}
-	assert.NoError(t, database.UpdateBlog(&blog))
+	current := database.GetBlogById(1)
+	if !assert.NotNil(t, current) {
+		return
+	}
+	blog := database.Blog{Id: 1, Title: "双十一", Article: "双十一来临喜洋洋，购物狂欢乐无边。电商盛宴满眼芳，心愿成真喜笑颜。", Version: current.Version}
+	assert.NoError(t, database.UpdateBlog(&blog, nil))
+	assert.Equal(t, current.Version+1, blog.Version)
+
+	// 基于旧版本的修改被拒绝
+	stale := database.Blog{Id: 1, Title: "双十一", Article: "过期的修改", Version: current.Version}
+	assert.ErrorIs(t, database.UpdateBlog(&stale, nil), database.ErrVersionConflict)
+}
+
+func TestUpdateBlogMeta(t *testing.T) {
+	category := "随笔"
+	blog := &database.Blog{UserId: 1, Title: "meta test", Article: "meta test"}
+	if !assert.NoError(t, database.CreateBlog(blog, &database.BlogMeta{Category: &category, Tags: []string{"go"}})) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.Equal(t, []string{"go"}, database.GetBlogTags(blog.Id))
+
+	// 版本冲突时分类和标签都不修改
+	other := "其他"
+	stale := &database.Blog{Id: blog.Id, UserId: 1, Title: "meta test", Article: "stale", Version: blog.Version - 1}
+	assert.ErrorIs(t, database.UpdateBlog(stale, &database.BlogMeta{Category: &other, Tags: []string{}}), database.ErrVersionConflict)
+	assert.Equal(t, category, database.GetBlogById(blog.Id).Category)
+	assert.Equal(t, []string{"go"}, database.GetBlogTags(blog.Id))
+
+	assert.NoError(t, database.UpdateBlog(blog, &database.BlogMeta{Category: &other, Tags: []string{}}))
+	assert.Equal(t, other, database.GetBlogById(blog.Id).Category)
+	assert.Empty(t, database.GetBlogTags(blog.Id))
+}
+
+func TestGetPublicBlogListStamp(t *testing.T) {
+	stamp, err := database.GetPublicBlogListStamp()
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, stamp.Count, int64(0))
+
+	_, err = database.GetPublicBlogStamp(-1)
+	assert.ErrorIs(t, err, database.ErrPublicBlogNotExist)
 }
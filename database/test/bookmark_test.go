@@ -0,0 +1,53 @@
+package test
+
+import (
+	"myblog/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmark(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "收藏测试", Article: "bookmark"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	defer database.RemoveBookmark(2, blog.Id)
+
+	// 只能收藏公开博客
+	assert.ErrorIs(t, database.AddBookmark(2, blog.Id), database.ErrPublicBlogNotExist)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+	assert.NoError(t, database.AddBookmark(2, blog.Id))
+	assert.NoError(t, database.AddBookmark(2, blog.Id))
+
+	bookmarked, err := database.IsBookmarked(2, blog.Id)
+	assert.NoError(t, err)
+	assert.True(t, bookmarked)
+	blogs, page, err := database.GetBookmarkedBlogs(2, &database.PageQuery{})
+	if assert.NoError(t, err) && assert.NotEmpty(t, blogs) {
+		assert.Equal(t, blog.Id, blogs[0].Id)
+		assert.Equal(t, "收藏测试", blogs[0].Title)
+		assert.EqualValues(t, len(blogs), page.Total)
+	}
+
+	// 取消发布后隐藏但不删除, 重新发布后恢复显示
+	assert.NoError(t, database.UnpublishBlog(blog.Id, blog.UserId))
+	blogs, _, err = database.GetBookmarkedBlogs(2, &database.PageQuery{})
+	assert.NoError(t, err)
+	for _, item := range blogs {
+		assert.NotEqual(t, blog.Id, item.Id)
+	}
+	bookmarked, _ = database.IsBookmarked(2, blog.Id)
+	assert.True(t, bookmarked)
+
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+	blogs, _, err = database.GetBookmarkedBlogs(2, &database.PageQuery{})
+	if assert.NoError(t, err) && assert.NotEmpty(t, blogs) {
+		assert.Equal(t, blog.Id, blogs[0].Id)
+	}
+
+	assert.NoError(t, database.RemoveBookmark(2, blog.Id))
+	bookmarked, _ = database.IsBookmarked(2, blog.Id)
+	assert.False(t, bookmarked)
+}
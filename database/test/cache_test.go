@@ -0,0 +1,41 @@
+package test
+
+import (
+	"myblog/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicBlogCacheInvalidation(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "缓存测试", Article: "before"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+
+	// 未公开时缓存的“不存在”在发布后失效
+	assert.Nil(t, database.GetPublicBlogById(blog.Id))
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+	detail := database.GetPublicBlogById(blog.Id)
+	if !assert.NotNil(t, detail) {
+		return
+	}
+	assert.Equal(t, "before", detail.Article)
+
+	blog.Article = "after"
+	assert.NoError(t, database.UpdateBlog(blog, nil))
+	assert.Equal(t, "after", database.GetPublicBlogById(blog.Id).Article)
+
+	comments, _, err := database.GetPublicBlogComments(blog.Id, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+	_, err = database.CreatePublicBlogComment(blog.Id, blog.UserId, "第一条评论")
+	assert.NoError(t, err)
+	comments, _, err = database.GetPublicBlogComments(blog.Id, nil)
+	assert.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	assert.NoError(t, database.UnpublishBlog(blog.Id, blog.UserId))
+	assert.Nil(t, database.GetPublicBlogById(blog.Id))
+}
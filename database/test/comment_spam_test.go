@@ -0,0 +1,76 @@
+package test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"myblog/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrCommentRate(t *testing.T) {
+	id := "test_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		count, remaining, err := database.IncrCommentRate("user", id, time.Minute, now)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.EqualValues(t, i, count)
+		assert.True(t, remaining > 0 && remaining <= time.Minute)
+	}
+
+	// 下一个窗口重新计数
+	count, _, err := database.IncrCommentRate("user", id, time.Minute, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+}
+
+func TestClaimComment(t *testing.T) {
+	uid := int(time.Now().UnixNano() % 1000000)
+	claimed, err := database.ClaimComment(uid, "Hello  World", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// 只有空白和大小写不同的内容同样视为重复
+	claimed, err = database.ClaimComment(uid, " hello world ", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	claimed, err = database.ClaimComment(uid+1, "Hello World", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// 撤销登记后可以再次发表
+	assert.NoError(t, database.ReleaseComment(uid, "Hello World"))
+	claimed, err = database.ClaimComment(uid, "Hello World", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestClaimCommentConcurrent(t *testing.T) {
+	uid := int(time.Now().UnixNano()%1000000) + 1000000
+	const count = 10
+	results := make([]bool, count)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = database.ClaimComment(uid, "same content", time.Minute)
+		}(i)
+	}
+	wg.Wait()
+
+	// 同时提交相同的内容只有一个能通过
+	claimed := 0
+	for _, ok := range results {
+		if ok {
+			claimed++
+		}
+	}
+	assert.Equal(t, 1, claimed)
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"myblog/database"
 
@@ -11,25 +12,25 @@ import (
 
 func TestCreatePublicBlogCommentInvalidInput(t *testing.T) {
 	t.Run("invalid blog id", func(t *testing.T) {
-		err := database.CreatePublicBlogComment(0, 1, "hello")
+		_, err := database.CreatePublicBlogComment(0, 1, "hello")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid blog id")
 	})
 
 	t.Run("invalid user id", func(t *testing.T) {
-		err := database.CreatePublicBlogComment(1, 0, "hello")
+		_, err := database.CreatePublicBlogComment(1, 0, "hello")
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid blog id")
 	})
 
 	t.Run("empty content", func(t *testing.T) {
-		err := database.CreatePublicBlogComment(1, 1, "   ")
+		_, err := database.CreatePublicBlogComment(1, 1, "   ")
 		assert.ErrorIs(t, err, database.ErrInvalidCommentContent)
 	})
 
 	t.Run("content too long", func(t *testing.T) {
 		tooLong := strings.Repeat("a", 1001)
-		err := database.CreatePublicBlogComment(1, 1, tooLong)
+		_, err := database.CreatePublicBlogComment(1, 1, tooLong)
 		assert.ErrorIs(t, err, database.ErrInvalidCommentContent)
 	})
 }
@@ -50,3 +51,224 @@ func TestDeletePublicBlogCommentInvalidInput(t *testing.T) {
 		assert.ErrorIs(t, err, database.ErrInvalidDeleteComment)
 	})
 }
+
+func TestReplyPublicBlogCommentInvalidParent(t *testing.T) {
+	_, err := database.ReplyPublicBlogComment(1, 1, -1, "hello")
+	assert.ErrorIs(t, err, database.ErrInvalidParentComment)
+}
+
+func TestGetPublicBlogCommentsInvalidSort(t *testing.T) {
+	_, _, err := database.GetPublicBlogComments(1, &database.PublicBlogCommentQuery{Sort: "hottest"})
+	assert.ErrorIs(t, err, database.ErrInvalidCommentSort)
+}
+
+func TestDeletePublicBlogCommentTombstone(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "评论回复测试", Article: "thread"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+
+	parent, err := database.CreatePublicBlogComment(blog.Id, 1, "parent")
+	if !assert.NoError(t, err) {
+		return
+	}
+	reply, err := database.ReplyPublicBlogComment(blog.Id, 2, parent.Id, "reply")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, parent.Id, reply.ParentId)
+	assert.Equal(t, "reply", reply.Content)
+
+	// 有回复的评论删除后保留为占位, 不能再回复
+	assert.NoError(t, database.DeletePublicBlogComment(blog.Id, parent.Id, 1))
+	comments, _, err := database.GetPublicBlogComments(blog.Id, nil)
+	if assert.NoError(t, err) && assert.Len(t, comments, 2) {
+		assert.Equal(t, parent.Id, comments[0].Id)
+		assert.True(t, comments[0].Deleted)
+		assert.Empty(t, comments[0].Content)
+	}
+	_, err = database.ReplyPublicBlogComment(blog.Id, 2, parent.Id, "again")
+	assert.ErrorIs(t, err, database.ErrInvalidParentComment)
+	assert.ErrorIs(t, database.DeletePublicBlogComment(blog.Id, parent.Id, 1), database.ErrCommentNotExist)
+
+	// 最后一条回复删除后占位一并删除
+	assert.NoError(t, database.DeletePublicBlogComment(blog.Id, reply.Id, 2))
+	comments, _, err = database.GetPublicBlogComments(blog.Id, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+}
+
+func TestGetPublicBlogCommentsPage(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "评论分页测试", Article: "page"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+
+	var ids []int
+	for _, content := range []string{"first", "second", "third"} {
+		comment, err := database.CreatePublicBlogComment(blog.Id, 1, content)
+		if !assert.NoError(t, err) {
+			return
+		}
+		ids = append(ids, comment.Id)
+	}
+	reply, err := database.ReplyPublicBlogComment(blog.Id, 2, ids[2], "reply")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 最新的顶层评论连同回复一起返回, 总数只计顶层评论
+	query := &database.PublicBlogCommentQuery{PageQuery: database.PageQuery{Limit: 2}}
+	comments, page, err := database.GetPublicBlogComments(blog.Id, query)
+	if !assert.NoError(t, err) || !assert.Len(t, comments, 3) {
+		return
+	}
+	assert.Equal(t, []int{ids[2], ids[1], reply.Id}, []int{comments[0].Id, comments[1].Id, comments[2].Id})
+	assert.EqualValues(t, 3, page.Total)
+	assert.NotEmpty(t, page.Next)
+
+	query.Cursor = page.Next
+	comments, page, err = database.GetPublicBlogComments(blog.Id, query)
+	if assert.NoError(t, err) && assert.Len(t, comments, 1) {
+		assert.Equal(t, ids[0], comments[0].Id)
+		assert.Empty(t, page.Next)
+		assert.NotEmpty(t, page.Prev)
+	}
+
+	comments, _, err = database.GetPublicBlogComments(blog.Id, &database.PublicBlogCommentQuery{Sort: database.CommentSortOldest})
+	if assert.NoError(t, err) && assert.Len(t, comments, 4) {
+		assert.Equal(t, []int{ids[0], ids[1], ids[2], reply.Id}, []int{comments[0].Id, comments[1].Id, comments[2].Id, comments[3].Id})
+	}
+}
+
+func TestEditPublicBlogCommentInvalidInput(t *testing.T) {
+	_, err := database.EditPublicBlogComment(1, 0, 1, "hello", 0)
+	assert.ErrorIs(t, err, database.ErrInvalidEditComment)
+
+	_, err = database.EditPublicBlogComment(1, 1, 1, strings.Repeat("a", 1001), 0)
+	assert.ErrorIs(t, err, database.ErrInvalidCommentContent)
+}
+
+func TestEditPublicBlogComment(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "评论编辑测试", Article: "edit"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+
+	comment, err := database.CreatePublicBlogComment(blog.Id, 1, "before")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, comment.EditTime)
+
+	_, err = database.EditPublicBlogComment(blog.Id, comment.Id, 2, "others", 0)
+	assert.ErrorIs(t, err, database.ErrCommentEditDenied)
+
+	edited, err := database.EditPublicBlogComment(blog.Id, comment.Id, 1, " after ", time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "after", edited.Content)
+		assert.NotNil(t, edited.EditTime)
+	}
+	comments, _, err := database.GetPublicBlogComments(blog.Id, nil)
+	if assert.NoError(t, err) && assert.Len(t, comments, 1) {
+		assert.Equal(t, "after", comments[0].Content)
+		assert.NotNil(t, comments[0].EditTime)
+	}
+
+	// 原内容保存在历史中, 内容不变时不追加历史
+	_, err = database.EditPublicBlogComment(blog.Id, comment.Id, 1, "after", time.Hour)
+	assert.NoError(t, err)
+	histories, err := database.GetCommentHistory(comment.Id)
+	if assert.NoError(t, err) && assert.Len(t, histories, 1) {
+		assert.Equal(t, "before", histories[0].Content)
+	}
+
+	// 超过修改时限
+	_, err = database.EditPublicBlogComment(blog.Id, comment.Id, 1, "late", time.Nanosecond)
+	assert.ErrorIs(t, err, database.ErrCommentEditExpired)
+
+	// 删除评论后历史保留, 删除前的内容也记入历史
+	assert.NoError(t, database.DeletePublicBlogComment(blog.Id, comment.Id, 1))
+	histories, err = database.GetCommentHistory(comment.Id)
+	if assert.NoError(t, err) && assert.Len(t, histories, 2) {
+		assert.Equal(t, "after", histories[0].Content)
+		assert.Equal(t, "before", histories[1].Content)
+		assert.Equal(t, blog.Id, histories[0].BlogId)
+		assert.Equal(t, 1, histories[0].UserId)
+	}
+}
+
+func TestSetBlogCommentModeInvalid(t *testing.T) {
+	assert.ErrorIs(t, database.SetBlogCommentMode(1, "moderated"), database.ErrInvalidCommentMode)
+	assert.ErrorIs(t, database.SetCommentStatus(1, 1, database.CommentStatusPending), database.ErrInvalidCommentStatus)
+	_, _, err := database.GetBlogCommentsByStatus(1, "spam", &database.PageQuery{})
+	assert.ErrorIs(t, err, database.ErrInvalidCommentStatus)
+}
+
+func TestCommentModeration(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "评论审核测试", Article: "moderation"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+
+	// 博客作者可以删除任何人的评论
+	comment, err := database.CreatePublicBlogComment(blog.Id, 2, "abuse")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, database.CommentStatusApproved, comment.Status)
+	assert.ErrorIs(t, database.DeletePublicBlogComment(blog.Id, comment.Id, 3), database.ErrCommentNoPermission)
+	assert.NoError(t, database.DeletePublicBlogComment(blog.Id, comment.Id, 1))
+
+	// 开启审核后新评论等待审核, 博客作者自己的评论直接公开
+	assert.NoError(t, database.SetBlogCommentMode(blog.Id, database.CommentModeApproval))
+	assert.Equal(t, database.CommentModeApproval, database.GetBlogCommentMode(blog.Id))
+	pending, err := database.CreatePublicBlogComment(blog.Id, 2, "pending")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, database.CommentStatusPending, pending.Status)
+	own, err := database.CreatePublicBlogComment(blog.Id, 1, "own")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, database.CommentStatusApproved, own.Status)
+	_, err = database.ReplyPublicBlogComment(blog.Id, 1, pending.Id, "reply")
+	assert.ErrorIs(t, err, database.ErrInvalidParentComment)
+
+	comments, _, err := database.GetPublicBlogComments(blog.Id, nil)
+	if assert.NoError(t, err) && assert.Len(t, comments, 1) {
+		assert.Equal(t, own.Id, comments[0].Id)
+	}
+	waiting, page, err := database.GetBlogCommentsByStatus(blog.Id, database.CommentStatusPending, &database.PageQuery{})
+	if assert.NoError(t, err) && assert.Len(t, waiting, 1) {
+		assert.Equal(t, pending.Id, waiting[0].Id)
+		assert.EqualValues(t, 1, page.Total)
+	}
+
+	// 通过后公开显示, 隐藏后不再显示
+	assert.NoError(t, database.SetCommentStatus(blog.Id, pending.Id, database.CommentStatusApproved))
+	comments, _, err = database.GetPublicBlogComments(blog.Id, nil)
+	assert.NoError(t, err)
+	assert.Len(t, comments, 2)
+	assert.NoError(t, database.SetCommentStatus(blog.Id, own.Id, database.CommentStatusHidden))
+	comments, _, err = database.GetPublicBlogComments(blog.Id, nil)
+	if assert.NoError(t, err) && assert.Len(t, comments, 1) {
+		assert.Equal(t, pending.Id, comments[0].Id)
+	}
+
+	// 关闭评论后不能发表或修改
+	assert.NoError(t, database.SetBlogCommentMode(blog.Id, database.CommentModeClosed))
+	_, err = database.CreatePublicBlogComment(blog.Id, 2, "closed")
+	assert.ErrorIs(t, err, database.ErrCommentsClosed)
+	_, err = database.EditPublicBlogComment(blog.Id, pending.Id, 2, "edit", 0)
+	assert.ErrorIs(t, err, database.ErrCommentsClosed)
+}
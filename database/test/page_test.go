@@ -0,0 +1,23 @@
+package test
+
+import (
+	"testing"
+
+	"myblog/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"!!!", "bm90LWEtY3Vyc29y", "eC4xLjE"} { // 非 base64 / "not-a-cursor" / "x.1.1"
+		t.Run(cursor, func(t *testing.T) {
+			_, _, err := database.GetPublicBlogList(&database.PublicBlogQuery{
+				PageQuery: database.PageQuery{Cursor: cursor},
+			})
+			assert.ErrorIs(t, err, database.ErrInvalidCursor)
+
+			_, _, err = database.GetBlogByUserId(1, &database.PageQuery{Cursor: cursor})
+			assert.ErrorIs(t, err, database.ErrInvalidCursor)
+		})
+	}
+}
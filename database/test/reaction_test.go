@@ -0,0 +1,46 @@
+package test
+
+import (
+	"myblog/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlogReaction(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "表态测试", Article: "reaction"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+
+	// 未公开的博客不能表态
+	assert.ErrorIs(t, database.AddBlogReaction(blog.Id, 2, "like"), database.ErrPublicBlogNotExist)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+	assert.ErrorIs(t, database.AddBlogReaction(blog.Id, 2, "dislike"), database.ErrInvalidReaction)
+
+	// 重复添加和取消的结果不变
+	assert.NoError(t, database.AddBlogReaction(blog.Id, 2, "like"))
+	assert.NoError(t, database.AddBlogReaction(blog.Id, 2, "like"))
+	assert.NoError(t, database.AddBlogReaction(blog.Id, 2, "love"))
+	assert.NoError(t, database.AddBlogReaction(blog.Id, 3, "like"))
+
+	summary, err := database.GetBlogReactionSummary(blog.Id, 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ElementsMatch(t, []string{"like", "love"}, summary.Mine)
+	if assert.Len(t, summary.Counts, 2) {
+		assert.Equal(t, "like", summary.Counts[0].Kind)
+		assert.Equal(t, int64(2), summary.Counts[0].Count)
+		assert.Equal(t, int64(1), summary.Counts[1].Count)
+	}
+
+	assert.NoError(t, database.RemoveBlogReaction(blog.Id, 2, "like"))
+	assert.NoError(t, database.RemoveBlogReaction(blog.Id, 2, "like"))
+	summary, err = database.GetBlogReactionSummary(blog.Id, 0)
+	if assert.NoError(t, err) {
+		assert.Empty(t, summary.Mine)
+		assert.Equal(t, int64(1), summary.Counts[0].Count)
+	}
+}
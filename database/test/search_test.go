@@ -0,0 +1,28 @@
+package test
+
+import (
+	"myblog/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchPublicBlogsSingleCharacter(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "我家的猫", Article: "今天猫又睡了一整天"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+	assert.NoError(t, database.PublishBlog(blog.Id, blog.UserId))
+
+	// 短于 ngram 分词长度的查询同样能搜到
+	results, err := database.SearchPublicBlogs("猫")
+	assert.NoError(t, err)
+	found := false
+	for _, result := range results {
+		if result.Id == blog.Id {
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"myblog/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlogSlugFollowsTitle(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "别名测试", Article: "slug"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+
+	oldSlug := database.GetBlogSlug(blog.Id)
+	assert.True(t, strings.HasPrefix(oldSlug, "bie-ming-ce-shi"), oldSlug)
+
+	blog.Title = "别名测试 改名"
+	assert.NoError(t, database.UpdateBlog(blog, nil))
+	newSlug := database.GetBlogSlug(blog.Id)
+	assert.True(t, strings.HasPrefix(newSlug, "bie-ming-ce-shi-gai-ming"), newSlug)
+
+	// 旧别名仍然指向这篇博客, 并给出当前别名用于跳转
+	bid, current, err := database.ResolveSlug(oldSlug)
+	assert.NoError(t, err)
+	assert.Equal(t, blog.Id, bid)
+	assert.Equal(t, newSlug, current)
+
+	_, _, err = database.ResolveSlug("no-such-slug-at-all-0")
+	assert.ErrorIs(t, err, database.ErrSlugNotExist)
+}
+
+func TestBlogSlugConcurrentCreate(t *testing.T) {
+	const count = 5
+	blogs := make([]*database.Blog, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := range blogs {
+		blogs[i] = &database.Blog{UserId: 1, Title: "并发别名测试", Article: "slug"}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = database.CreateBlog(blogs[i], nil)
+		}(i)
+	}
+	wg.Wait()
+
+	// 同时创建同名博客都能成功, 且各自得到不同的别名
+	slugs := make(map[string]bool, count)
+	for i, blog := range blogs {
+		if !assert.NoError(t, errs[i]) {
+			continue
+		}
+		defer database.DeleteBlog(blog.Id, blog.UserId)
+		slug := database.GetBlogSlug(blog.Id)
+		assert.False(t, slugs[slug], slug)
+		slugs[slug] = true
+	}
+}
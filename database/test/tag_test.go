@@ -0,0 +1,33 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"myblog/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	t.Run("split and normalize", func(t *testing.T) {
+		assert.Equal(t, []string{"go", "后端", "web dev"}, database.ParseTags(" Go, 后端，GO; Web Dev ;"))
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		assert.Empty(t, database.ParseTags(" , ，"))
+	})
+
+	t.Run("too long tag is dropped", func(t *testing.T) {
+		assert.Equal(t, []string{"ok"}, database.ParseTags(strings.Repeat("a", 33)+",ok"))
+	})
+
+	t.Run("at most 10 tags", func(t *testing.T) {
+		assert.Len(t, database.ParseTags("a,b,c,d,e,f,g,h,i,j,k,l"), 10)
+	})
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	assert.Equal(t, "backend", database.NormalizeCategory("  backend "))
+	assert.Len(t, []rune(database.NormalizeCategory(strings.Repeat("分", 70))), 64)
+}
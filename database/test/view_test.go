@@ -0,0 +1,48 @@
+package test
+
+import (
+	"myblog/database"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlogViewFlush(t *testing.T) {
+	blog := &database.Blog{UserId: 1, Title: "阅读量测试", Article: "views"}
+	if !assert.NoError(t, database.CreateBlog(blog, nil)) {
+		return
+	}
+	defer database.DeleteBlog(blog.Id, blog.UserId)
+
+	now := time.Now()
+	assert.NoError(t, database.RecordBlogView(blog.Id, "visitor-a", now))
+	assert.NoError(t, database.RecordBlogView(blog.Id, "visitor-a", now))
+	assert.NoError(t, database.RecordBlogView(blog.Id, "visitor-b", now))
+	_, err := database.FlushBlogViews()
+	assert.NoError(t, err)
+
+	total := database.GetBlogViewTotal(blog.Id)
+	assert.Equal(t, int64(3), total.Views)
+	assert.Equal(t, int64(2), total.Visitors)
+
+	// 重复写入同一天的累计值, 结果不变
+	assert.NoError(t, database.RecordBlogView(blog.Id, "visitor-b", now))
+	_, err = database.FlushBlogViews()
+	assert.NoError(t, err)
+	_, err = database.FlushBlogViews()
+	assert.NoError(t, err)
+	total = database.GetBlogViewTotal(blog.Id)
+	assert.Equal(t, int64(4), total.Views)
+	assert.Equal(t, int64(2), total.Visitors)
+
+	// 另一个副本读到的较早的累计值后写入, 不会把计数改小
+	member := now.Format("2006-01-02") + "_" + strconv.Itoa(blog.Id)
+	client := database.InitRedisClient()
+	assert.NoError(t, client.Set(database.VIEW_PREFIX+"count_"+member, 2, time.Hour).Err())
+	assert.NoError(t, client.SAdd(database.VIEW_DIRTY, member).Err())
+	_, err = database.FlushBlogViews()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), database.GetBlogViewTotal(blog.Id).Views)
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type TrashedBlog struct {
+	Id         int       `gorm:"column:id"`
+	Title      string    `gorm:"column:title"`
+	DeleteTime time.Time `gorm:"column:delete_time"`
+}
+
+// DeleteBlog 把博客移入回收站: 取消公开和发布计划, 隐藏评论, 软删除博客本身
+func DeleteBlog(bid, uid int) error {
+	if bid <= 0 || uid <= 0 {
+		return ErrBlogNotExist
+	}
+	ensurePublicBlogTable()
+	ensureBlogCommentTable()
+	ensureBlogScheduleTable()
+	db := GetBlogDBConnection()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", bid, uid).Delete(&Blog{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBlogNotExist
+		}
+		if err := tx.Where("blog_id = ?", bid).Delete(&PublicBlog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id = ?", bid).Delete(&BlogSchedule{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&BlogComment{}).Where("blog_id = ?", bid).Update("blog_trashed", true).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidatePublicBlog(bid)
+	invalidatePublicBlogComments(bid)
+	return nil
+}
+
+// RestoreBlog 把博客从回收站恢复为未公开状态, 并重新显示评论
+func RestoreBlog(bid, uid int) error {
+	if bid <= 0 || uid <= 0 {
+		return ErrBlogNotExist
+	}
+	ensureBlogCommentTable()
+	ensureBlogSlugTable()
+	db := GetBlogDBConnection()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&Blog{}).
+			Where("id = ? AND user_id = ? AND delete_time IS NOT NULL", bid, uid).
+			Update("delete_time", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBlogNotExist
+		}
+		if err := tx.Model(&BlogComment{}).Where("blog_id = ?", bid).Update("blog_trashed", false).Error; err != nil {
+			return err
+		}
+		// 补别名时跳过了回收站中的博客, 恢复时补上
+		blog := &Blog{}
+		if err := tx.Select("id, title").Where("id = ?", bid).Take(blog).Error; err != nil {
+			return err
+		}
+		return ensureBlogHasSlug(tx, blog.Id, blog.Title)
+	})
+	if err != nil {
+		return err
+	}
+	invalidatePublicBlog(bid)
+	invalidatePublicBlogComments(bid)
+	return nil
+}
+
+// GetTrashedBlogs 返回用户回收站中的博客, 最近删除的在前
+func GetTrashedBlogs(uid int) []*TrashedBlog {
+	if uid <= 0 {
+		return nil
+	}
+	db := GetBlogDBConnection()
+
+	var blogs []*TrashedBlog
+	err := db.Unscoped().Model(&Blog{}).
+		Select("id, title, delete_time").
+		Where("user_id = ? AND delete_time IS NOT NULL", uid).
+		Order("delete_time DESC").
+		Find(&blogs).Error
+	if err != nil {
+		zap.L().Error("get trashed blogs failed", zap.Int("uid", uid), zap.Error(err))
+		return nil
+	}
+	return blogs
+}
+
+// PurgeTrashedBlogs 彻底删除在回收站中停留超过 retention 的博客及其关联数据, 返回删除的博客数
+func PurgeTrashedBlogs(retention time.Duration) (int, error) {
+	ensurePublicBlogTable()
+	ensureBlogCommentTable()
+	ensureBlogCommentHistoryTable()
+	ensureBlogCommentSettingTable()
+	ensureBlogRevisionTable()
+	ensureTagTable()
+	ensureBlogScheduleTable()
+	ensureBlogSlugTable()
+	ensureBlogViewTable()
+	ensureBlogReactionTable()
+	ensureBlogBookmarkTable()
+	db := GetBlogDBConnection()
+
+	var bids []int
+	err := db.Unscoped().Model(&Blog{}).
+		Where("delete_time IS NOT NULL AND delete_time < ?", time.Now().Add(-retention)).
+		Pluck("id", &bids).Error
+	if err != nil || len(bids) == 0 {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogCommentHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogComment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogCommentSetting{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&PublicBlog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogSchedule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogSlug{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogView{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogReaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("blog_id IN ?", bids).Delete(&BlogBookmark{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ? AND delete_time IS NOT NULL", bids).Delete(&Blog{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(bids), nil
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Upload 按内容哈希去重的上传文件, 相同内容只保存一份
+type Upload struct {
+	Id         int       `gorm:"column:id;primaryKey"`
+	Hash       string    `gorm:"column:hash;type:char(64);not null;uniqueIndex"` // sha256 十六进制
+	Size       int64     `gorm:"column:size;not null"`
+	MimeType   string    `gorm:"column:mime_type;type:varchar(64);not null"`
+	Ext        string    `gorm:"column:ext;type:varchar(16);not null"`
+	StorageKey string    `gorm:"column:storage_key;type:varchar(128);not null"`
+	CreateTime time.Time `gorm:"column:create_time"`
+}
+
+// UploadOwner 记录哪些用户上传过该文件, 同一文件可以属于多个用户
+type UploadOwner struct {
+	UploadId   int       `gorm:"column:upload_id;primaryKey"`
+	UserId     int       `gorm:"column:user_id;primaryKey;index"`
+	FileName   string    `gorm:"column:file_name;type:varchar(255)"` // 用户上传时的原始文件名
+	CreateTime time.Time `gorm:"column:create_time"`
+}
+
+type UserUpload struct {
+	Upload
+	FileName   string    `gorm:"column:file_name"`
+	UploadTime time.Time `gorm:"column:upload_time"`
+}
+
+func (Upload) TableName() string {
+	return "upload"
+}
+
+func (UploadOwner) TableName() string {
+	return "upload_owner"
+}
+
+var uploadMigrator sync.Once
+
+func ensureUploadTable() {
+	db := GetBlogDBConnection()
+	uploadMigrator.Do(func() {
+		if err := db.AutoMigrate(&Upload{}, &UploadOwner{}); err != nil {
+			zap.L().Error("migrate upload failed", zap.Error(err))
+		}
+	})
+}
+
+func GetUploadByHash(hash string) *Upload {
+	ensureUploadTable()
+	db := GetBlogDBConnection()
+
+	upload := &Upload{}
+	if err := db.Where("hash = ?", hash).First(upload).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zap.L().Error("get upload by hash failed", zap.String("hash", hash), zap.Error(err))
+		}
+		return nil
+	}
+	return upload
+}
+
+// SaveUpload 记录上传文件及其上传者。文件已存在(哈希相同)时复用已有记录, upload 会被填充为库中的记录
+func SaveUpload(upload *Upload, uid int, fileName string) error {
+	ensureUploadTable()
+	db := GetBlogDBConnection()
+
+	if upload.CreateTime.IsZero() {
+		upload.CreateTime = time.Now()
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(upload).Error
+		if err != nil {
+			return err
+		}
+		// 冲突时插入不会生效, 统一重新读取库中的记录
+		saved := &Upload{}
+		if err := tx.Where("hash = ?", upload.Hash).First(saved).Error; err != nil {
+			return err
+		}
+		*upload = *saved
+		owner := &UploadOwner{
+			UploadId:   upload.Id,
+			UserId:     uid,
+			FileName:   fileName,
+			CreateTime: time.Now(),
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(owner).Error
+	})
+}
+
+// GetUploadsByUser 返回用户上传过的文件, 最近上传的在前
+func GetUploadsByUser(uid int) []*UserUpload {
+	ensureUploadTable()
+	db := GetBlogDBConnection()
+
+	var uploads []*UserUpload
+	err := db.Table("upload_owner o").
+		Select("u.*, o.file_name, o.create_time AS upload_time").
+		Joins("INNER JOIN upload u ON u.id = o.upload_id").
+		Where("o.user_id = ?", uid).
+		Order("o.create_time DESC").
+		Find(&uploads).Error
+	if err != nil {
+		zap.L().Error("get uploads of user failed", zap.Int("uid", uid), zap.Error(err))
+		return nil
+	}
+	return uploads
+}
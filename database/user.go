@@ -37,6 +37,19 @@ func GetUserByName(name string) *User {
 	return &user
 }
 
+// 根据用户id检索用户
+func GetUserById(uid int) *User {
+	db := GetBlogDBConnection()
+	var user User
+	if err := db.Select(_all_user_field).Where("id=?", uid).First(&user).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zap.L().Error("get user by id failed", zap.Int("uid", uid), zap.Error(err))
+		}
+		return nil
+	}
+	return &user
+}
+
 func CreateUser(name, password string) error {
 	db := GetBlogDBConnection()
 	user := &User{
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 阅读量先在 Redis 中按博客按天累计: 浏览次数用计数器, 访客用 HyperLogLog 估算去重后的人数。
+// 有变化的 (日期, 博客) 记在待写入集合中, 由后台任务定期把当天的累计值写入 MySQL
+const (
+	VIEW_PREFIX   = "blog_view_"
+	VIEW_DIRTY    = VIEW_PREFIX + "dirty"
+	VIEW_EXPIRE   = 3 * 24 * time.Hour // 当天结束并写入 MySQL 后即可过期, 留出余量应对写入失败
+	viewDayLayout = "2006-01-02"
+
+	viewFlushBatch = 1000 // 每次写入的最大行数
+)
+
+// BlogView 一篇博客一天的阅读量
+type BlogView struct {
+	BlogId   int       `gorm:"column:blog_id;primaryKey"`
+	Day      time.Time `gorm:"column:day;primaryKey;type:date"`
+	Views    int64     `gorm:"column:views;not null"`
+	Visitors int64     `gorm:"column:visitors;not null"` // 当天去重后的访客数, HyperLogLog 估算值
+}
+
+// BlogViewTotal 一篇博客累计的阅读量, 访客数是每天去重后的和
+type BlogViewTotal struct {
+	BlogId   int   `gorm:"column:blog_id" json:"-"`
+	Views    int64 `gorm:"column:views" json:"views"`
+	Visitors int64 `gorm:"column:visitors" json:"visitors"`
+}
+
+func (BlogView) TableName() string {
+	return "blog_view"
+}
+
+var blogViewMigrator sync.Once
+
+func ensureBlogViewTable() {
+	db := GetBlogDBConnection()
+	blogViewMigrator.Do(func() {
+		if err := db.AutoMigrate(&BlogView{}); err != nil {
+			zap.L().Error("migrate blog_view failed", zap.Error(err))
+		}
+	})
+}
+
+func viewCountKey(member string) string {
+	return VIEW_PREFIX + "count_" + member
+}
+
+func viewVisitorKey(member string) string {
+	return VIEW_PREFIX + "uv_" + member
+}
+
+// viewMember 待写入集合中的成员, 格式为 <日期>_<博客 id>
+func viewMember(day string, bid int) string {
+	return day + "_" + strconv.Itoa(bid)
+}
+
+func parseViewMember(member string) (time.Time, int, error) {
+	day, bid, ok := strings.Cut(member, "_")
+	if !ok {
+		return time.Time{}, 0, fmt.Errorf("invalid view member %q", member)
+	}
+	t, err := time.ParseInLocation(viewDayLayout, day, time.Local)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	id, err := strconv.Atoi(bid)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return t, id, nil
+}
+
+// RecordBlogView 记录一次阅读, visitor 是访客的匿名标识
+func RecordBlogView(bid int, visitor string, now time.Time) error {
+	member := viewMember(now.Format(viewDayLayout), bid)
+	countKey, visitorKey := viewCountKey(member), viewVisitorKey(member)
+
+	pipe := InitRedisClient().TxPipeline()
+	pipe.Incr(countKey)
+	pipe.PFAdd(visitorKey, visitor)
+	pipe.Expire(countKey, VIEW_EXPIRE)
+	pipe.Expire(visitorKey, VIEW_EXPIRE)
+	pipe.SAdd(VIEW_DIRTY, member)
+	_, err := pipe.Exec()
+	return err
+}
+
+// FlushBlogViews 把有变化的阅读量写入 MySQL, 返回写入的行数。
+// 每次最多从待写入集合中取出 viewFlushBatch 个成员再读取计数, 持续有阅读时集合不断有新成员,
+// 剩下的留给下一次; 取出之后的新阅读会重新加入集合, 不会丢失。
+// 写入的是当天的累计值且只取较大的值, 重复或乱序写入结果不变, 多个副本同时执行也是安全的
+func FlushBlogViews() (int, error) {
+	ensureBlogViewTable()
+	client := InitRedisClient()
+	db := GetBlogDBConnection()
+
+	members, err := client.SPopN(VIEW_DIRTY, viewFlushBatch).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	for i, member := range members {
+		if err := flushBlogView(client, db, member); err != nil {
+			zap.L().Error("flush blog view failed", zap.String("member", member), zap.Error(err))
+			// 失败的和还没处理的成员放回集合, 下次重试
+			rest := make([]any, 0, len(members)-i)
+			for _, m := range members[i:] {
+				rest = append(rest, m)
+			}
+			client.SAdd(VIEW_DIRTY, rest...)
+			return i, err
+		}
+	}
+	return len(members), nil
+}
+
+func flushBlogView(client *redis.Client, db *gorm.DB, member string) error {
+	day, bid, err := parseViewMember(member)
+	if err != nil {
+		zap.L().Warn("drop invalid view member", zap.String("member", member), zap.Error(err))
+		return nil
+	}
+	views, err := client.Get(viewCountKey(member)).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	visitors, err := client.PFCount(viewVisitorKey(member)).Result()
+	if err != nil {
+		return err
+	}
+	if views == 0 {
+		return nil // 计数已过期
+	}
+
+	// 累计值只增不减, 只在新值更大时覆盖: 另一个副本读到的较早的值即使后写入, 也不会把计数改小
+	row := &BlogView{BlogId: bid, Day: day, Views: views, Visitors: visitors}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "blog_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"views":    gorm.Expr("GREATEST(views, VALUES(views))"),
+			"visitors": gorm.Expr("GREATEST(visitors, VALUES(visitors))"),
+		}),
+	}).Create(row).Error
+}
+
+// GetBlogViewTotals 批量查询博客累计的阅读量, 只包含已写入 MySQL 的部分; 没有阅读记录的博客不在结果中
+func GetBlogViewTotals(bids []int) map[int]*BlogViewTotal {
+	result := make(map[int]*BlogViewTotal, len(bids))
+	if len(bids) == 0 {
+		return result
+	}
+	ensureBlogViewTable()
+	db := GetBlogDBConnection()
+
+	var rows []*BlogViewTotal
+	err := db.Model(&BlogView{}).
+		Select("blog_id, SUM(views) AS views, SUM(visitors) AS visitors").
+		Where("blog_id IN ?", bids).
+		Group("blog_id").
+		Find(&rows).Error
+	if err != nil {
+		zap.L().Error("get blog view totals failed", zap.Ints("bids", bids), zap.Error(err))
+		return result
+	}
+	for _, row := range rows {
+		result[row.BlogId] = row
+	}
+	return result
+}
+
+// GetBlogViewTotal 一篇博客累计的阅读量, 没有记录时为 0
+func GetBlogViewTotal(bid int) *BlogViewTotal {
+	if total, ok := GetBlogViewTotals([]int{bid})[bid]; ok {
+		return total
+	}
+	return &BlogViewTotal{BlogId: bid}
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// /api/v1 下的接口只返回 JSON, 资源的字段名与 HTML 页面使用的模板数据保持一致
+
+type BlogResource struct {
+	Id         int       `json:"id"`
+	UserId     int       `json:"user_id"`
+	Title      string    `json:"title"`
+	Article    string    `json:"article"`
+	Category   string    `json:"category"`
+	Tags       []string  `json:"tags"`
+	IsPublic   bool      `json:"is_public"`
+	UpdateTime time.Time `json:"update_time"`
+	Version    int       `json:"version"` // 更新时原样传回, 或使用响应头中的 ETag 作为 If-Match
+}
+
+type PublicBlogResource struct {
+	Id          int       `json:"id"`
+	UserId      int       `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	Title       string    `json:"title"`
+	Category    string    `json:"category"`
+	Tags        []string  `json:"tags"`
+	Slug        string    `json:"slug"`
+	URL         string    `json:"url"`
+	Article     string    `json:"article,omitempty"`      // 仅详情接口返回
+	ArticleHTML string    `json:"article_html,omitempty"` // 仅详情接口返回
+	UpdateTime  time.Time `json:"update_time"`
+	PublishTime time.Time `json:"publish_time"`
+}
+
+// CommentResource 评论, 列表平铺返回本页的顶层评论及其全部回复, 客户端按 parent_id 组织回复
+type CommentResource struct {
+	Id         int        `json:"id"`
+	BlogId     int        `json:"blog_id"`
+	ParentId   int        `json:"parent_id"`
+	UserName   string     `json:"user_name"`
+	Content    string     `json:"content"`
+	Deleted    bool       `json:"deleted"` // 已删除但仍有回复, 内容为空
+	Status     string     `json:"status"`  // approved 为公开显示, pending 为等待博客作者审核
+	CreateTime time.Time  `json:"create_time"`
+	Edited     *time.Time `json:"edited"` // 最近一次修改的时间, 未修改过为 null
+}
+
+// ListResponse 分页列表, 翻页时把 page 中的 next_cursor/prev_cursor 作为 cursor 参数传回
+type ListResponse[T any] struct {
+	Items []T                `json:"items"`
+	Page  *database.PageInfo `json:"page"`
+}
+
+type ApiLoginRequest struct {
+	User string `json:"user" form:"user" binding:"required"`
+	Pass string `json:"pass" form:"pass" binding:"required,len=32"` // 密码的 md5
+}
+
+type ApiTokenRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+}
+
+type ApiBlogRequest struct {
+	Title    string  `json:"title" form:"title" binding:"required"`
+	Article  string  `json:"article" form:"article" binding:"required"`
+	Category *string `json:"category" form:"category"` // 更新时不传表示保持不变
+	Tags     *string `json:"tags" form:"tags"`         // 逗号分隔, 更新时不传表示保持不变
+}
+
+type ApiBlogUpdateRequest struct {
+	ApiBlogRequest
+	Version int `json:"version" form:"version"` // 编辑时基于的版本, 也可以通过 If-Match 请求头传递
+}
+
+type ApiPublishRequest struct {
+	PublishAt   string `json:"publish_at" form:"publish_at"`
+	UnpublishAt string `json:"unpublish_at" form:"unpublish_at"`
+}
+
+func apiLoginUid(ctx *gin.Context) (int, bool) {
+	loginUidValue, ok := ctx.Get("uid")
+	if !ok {
+		apperr.Write(ctx, apperr.ErrAuthFailed)
+		return 0, false
+	}
+	loginUid, ok := loginUidValue.(int)
+	if !ok || loginUid <= 0 {
+		apperr.Write(ctx, apperr.ErrAuthFailed)
+		return 0, false
+	}
+	return loginUid, true
+}
+
+// apiPathId 读取路径中的数字 id, 不合法时已写回 400
+func apiPathId(ctx *gin.Context, name string) (int, bool) {
+	id, err := strconv.Atoi(ctx.Param(name))
+	if err != nil || id <= 0 {
+		apperr.Write(ctx, apperr.InvalidField(name, "gt", "invalid "+name))
+		return 0, false
+	}
+	return id, true
+}
+
+// apiOwnedBlog 读取路径中的博客并校验当前用户是作者, 失败时已写回响应并返回 nil
+func apiOwnedBlog(ctx *gin.Context) *database.Blog {
+	bid, ok := apiPathId(ctx, "bid")
+	if !ok {
+		return nil
+	}
+	loginUid, ok := apiLoginUid(ctx)
+	if !ok {
+		return nil
+	}
+	blog := database.GetBlogById(bid)
+	if blog == nil {
+		apperr.Write(ctx, apperr.ErrBlogNotExist)
+		return nil
+	}
+	if blog.UserId != loginUid {
+		apperr.Write(ctx, apperr.ErrNoPermission.WithMessage("no permission to access blog"))
+		return nil
+	}
+	return blog
+}
+
+func newBlogResources(blogs []*database.Blog) []*BlogResource {
+	bids := make([]int, 0, len(blogs))
+	for _, blog := range blogs {
+		bids = append(bids, blog.Id)
+	}
+	tags := database.GetTagsOfBlogs(bids)
+	public := database.GetBlogsPublicStatus(bids)
+
+	resources := make([]*BlogResource, 0, len(blogs))
+	for _, blog := range blogs {
+		resources = append(resources, &BlogResource{
+			Id:         blog.Id,
+			UserId:     blog.UserId,
+			Title:      blog.Title,
+			Article:    blog.Article,
+			Category:   blog.Category,
+			Tags:       nonNilTags(tags[blog.Id]),
+			IsPublic:   public[blog.Id],
+			UpdateTime: blog.UpdateTime,
+			Version:    blog.Version,
+		})
+	}
+	return resources
+}
+
+func newPublicBlogResource(blog *database.PublicBlogPreview) *PublicBlogResource {
+	return &PublicBlogResource{
+		Id:          blog.Id,
+		UserId:      blog.UserId,
+		UserName:    blog.UserName,
+		Title:       blog.Title,
+		Category:    blog.Category,
+		Tags:        nonNilTags(blog.Tags),
+		Slug:        blog.Slug,
+		URL:         publicBlogPath(blog.Id, blog.Slug),
+		UpdateTime:  blog.UpdateTime,
+		PublishTime: blog.PublishTime,
+	}
+}
+
+func newCommentResource(comment *database.PublicBlogCommentItem) *CommentResource {
+	resource := &CommentResource{
+		Id:         comment.Id,
+		BlogId:     comment.BlogId,
+		ParentId:   comment.ParentId,
+		UserName:   comment.UserName,
+		Content:    comment.Content,
+		Deleted:    comment.Deleted,
+		Status:     comment.Status,
+		CreateTime: comment.CreateTime,
+		Edited:     comment.EditTime,
+	}
+	if comment.Deleted {
+		resource.UserName = ""
+		resource.Edited = nil
+	}
+	return resource
+}
+
+// nonNilTags 保证没有标签时输出 [] 而不是 null
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func NewApiLogin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := &ApiLoginRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		user := database.GetUserByName(request.User)
+		if user == nil || user.PassWd != request.Pass {
+			// 不区分用户不存在和密码错误, 避免被用来探测用户名
+			apperr.Write(ctx, apperr.ErrLoginFailed)
+			return
+		}
+		authToken, refreshToken, err := issueLoginToken(ctx, user)
+		if err != nil {
+			zap.L().Error("issue login token failed", zap.Int("uid", user.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("login failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"uid":           user.Id,
+			"token":         authToken,
+			"refresh_token": refreshToken,
+		})
+	}
+}
+
+func NewApiRegister() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := &ApiLoginRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		if database.GetUserByName(request.User) != nil {
+			apperr.Write(ctx, apperr.ErrUserExist)
+			return
+		}
+		if err := database.CreateUser(request.User, request.Pass); err != nil {
+			zap.L().Error("create user failed", zap.String("name", request.User), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("create user failed"))
+			return
+		}
+		user := database.GetUserByName(request.User)
+		if user == nil {
+			apperr.Write(ctx, apperr.Internal("create user failed"))
+			return
+		}
+		ctx.JSON(http.StatusCreated, gin.H{
+			"uid":  user.Id,
+			"name": user.Name,
+		})
+	}
+}
+
+func NewApiToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := &ApiTokenRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		authToken := database.GetToken(request.RefreshToken)
+		if len(authToken) == 0 {
+			apperr.Write(ctx, apperr.ErrInvalidToken)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"token": authToken})
+	}
+}
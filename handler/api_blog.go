@@ -0,0 +1,399 @@
+package handler
+
+import (
+	"errors"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/util"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NewApiBlogs 当前用户自己的博客, 包括未公开的
+func NewApiBlogs() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+		page, ok := parsePageQuery(ctx)
+		if !ok {
+			return
+		}
+		blogs, pageInfo, err := database.GetBlogByUserId(loginUid, page)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("get blog list failed", zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("get blog list failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, &ListResponse[*BlogResource]{
+			Items: newBlogResources(blogs),
+			Page:  pageInfo,
+		})
+	}
+}
+
+func NewApiBlog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := apiOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		ctx.Header("ETag", blogETag(blog.Version))
+		ctx.JSON(http.StatusOK, newBlogResources([]*database.Blog{blog})[0])
+	}
+}
+
+func NewApiBlogCreate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+		request := &ApiBlogRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+
+		blog := &database.Blog{
+			UserId:  loginUid,
+			Title:   request.Title,
+			Article: request.Article,
+		}
+		meta := &database.BlogMeta{Category: request.Category}
+		if request.Tags != nil {
+			meta.Tags = database.ParseTags(*request.Tags)
+		}
+		if err := database.CreateBlog(blog, meta); err != nil {
+			zap.L().Error("create blog failed", zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("create blog failed"))
+			return
+		}
+		ctx.Header("ETag", blogETag(blog.Version))
+		ctx.JSON(http.StatusCreated, newBlogResources([]*database.Blog{blog})[0])
+	}
+}
+
+func NewApiBlogUpdate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := apiOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		request := &ApiBlogUpdateRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		version, ok := expectedBlogVersion(ctx, request.Version)
+		if !ok {
+			return
+		}
+
+		blog.Title = request.Title
+		blog.Article = request.Article
+		blog.Version = version
+		meta := &database.BlogMeta{Category: request.Category}
+		if request.Tags != nil {
+			meta.Tags = database.ParseTags(*request.Tags)
+		}
+		err := database.UpdateBlog(blog, meta)
+		if errors.Is(err, database.ErrVersionConflict) {
+			writeVersionConflict(ctx, blog.Id)
+			return
+		}
+		if err != nil {
+			zap.L().Error("update blog failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("update blog failed"))
+			return
+		}
+		database.DeleteDraft(blog.UserId, blog.Id)
+
+		if updated := database.GetBlogById(blog.Id); updated != nil {
+			blog = updated
+		}
+		ctx.Header("ETag", blogETag(blog.Version))
+		ctx.JSON(http.StatusOK, newBlogResources([]*database.Blog{blog})[0])
+	}
+}
+
+func NewApiBlogDelete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := apiOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		if err := database.DeleteBlog(blog.Id, blog.UserId); err != nil {
+			if errors.Is(err, database.ErrBlogNotExist) {
+				apperr.Write(ctx, apperr.ErrBlogNotExist)
+				return
+			}
+			zap.L().Error("delete blog failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("delete blog failed"))
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func NewApiBlogPublish() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := apiOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		request := &ApiPublishRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		publishAt, unpublishAt, err := parsePublishSchedule(request.PublishAt, request.UnpublishAt)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+		if err := applyPublish(blog.Id, blog.UserId, publishAt, unpublishAt); err != nil {
+			zap.L().Error("publish blog failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("publish blog failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"id":           blog.Id,
+			"is_public":    publishAt == nil,
+			"publish_at":   publishAt,
+			"unpublish_at": unpublishAt,
+		})
+	}
+}
+
+func NewApiBlogUnpublish() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := apiOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		if err := database.UnpublishBlog(blog.Id, blog.UserId); err != nil {
+			zap.L().Error("unpublish blog failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("unpublish blog failed"))
+			return
+		}
+		if err := database.DeleteBlogSchedule(blog.Id); err != nil {
+			zap.L().Error("delete blog schedule failed", zap.Int("bid", blog.Id), zap.Error(err))
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func NewApiPublicBlogs() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		page, ok := parsePageQuery(ctx)
+		if !ok {
+			return
+		}
+		query := &database.PublicBlogQuery{
+			PageQuery: *page,
+			Tag:       ctx.Query("tag"),
+			Category:  ctx.Query("category"),
+		}
+		if value := ctx.Query("user_id"); len(value) > 0 {
+			uid, err := strconv.Atoi(value)
+			if err != nil || uid <= 0 {
+				apperr.Write(ctx, apperr.InvalidField("user_id", "gt", "invalid user_id"))
+				return
+			}
+			query.UserId = uid
+		}
+
+		blogs, pageInfo, err := database.GetPublicBlogList(query)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("get public blog list failed", zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("get public blog list failed"))
+			return
+		}
+		items := make([]*PublicBlogResource, 0, len(blogs))
+		for _, blog := range blogs {
+			items = append(items, newPublicBlogResource(blog))
+		}
+		ctx.JSON(http.StatusOK, &ListResponse[*PublicBlogResource]{
+			Items: items,
+			Page:  pageInfo,
+		})
+	}
+}
+
+func NewApiPublicBlog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, ok := apiPathId(ctx, "bid")
+		if !ok {
+			return
+		}
+		blog := database.GetPublicBlogById(bid)
+		if blog == nil {
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		resource := newPublicBlogResource(&database.PublicBlogPreview{
+			Id:          blog.Id,
+			UserId:      blog.UserId,
+			UserName:    blog.UserName,
+			Title:       blog.Title,
+			Category:    blog.Category,
+			Slug:        blog.Slug,
+			UpdateTime:  blog.UpdateTime,
+			PublishTime: blog.PublishTime,
+			Tags:        blog.Tags,
+		})
+		resource.Article = blog.Article
+		resource.ArticleHTML = string(util.RenderMarkdownCached(blog.Id, blog.UpdateTime, blog.Article))
+		ctx.JSON(http.StatusOK, resource)
+	}
+}
+
+func NewApiPublicBlogComments() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, ok := apiPathId(ctx, "bid")
+		if !ok {
+			return
+		}
+		query, ok := parseCommentQuery(ctx)
+		if !ok {
+			return
+		}
+		if database.GetPublicBlogById(bid) == nil {
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		comments, pageInfo, err := database.GetPublicBlogComments(bid, query)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get comments failed"))
+			return
+		}
+		items := make([]*CommentResource, 0, len(comments))
+		for _, comment := range comments {
+			items = append(items, newCommentResource(comment))
+		}
+		ctx.JSON(http.StatusOK, &ListResponse[*CommentResource]{Items: items, Page: pageInfo})
+	}
+}
+
+func NewApiPublicBlogCommentCreate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, ok := apiPathId(ctx, "bid")
+		if !ok {
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+		request := &CreateCommentRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+
+		policy := loadCommentSpamPolicy()
+		if err := policy.checkCreate(ctx, loginUid, request.Content); err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		comment, err := database.ReplyPublicBlogComment(bid, loginUid, request.ParentId, request.Content)
+		if err != nil {
+			policy.release(loginUid, request.Content)
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("create public blog comment failed", zap.Int("bid", bid), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("create comment failed"))
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, newCommentResource(comment))
+	}
+}
+
+func NewApiPublicBlogCommentUpdate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, ok := apiPathId(ctx, "bid")
+		if !ok {
+			return
+		}
+		cid, ok := apiPathId(ctx, "cid")
+		if !ok {
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+		request := &UpdateCommentRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+
+		if err := loadCommentSpamPolicy().checkContent(request.Content); err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		comment, err := database.EditPublicBlogComment(bid, cid, loginUid, request.Content, commentEditWindow())
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("edit public blog comment failed", zap.Int("bid", bid), zap.Int("cid", cid), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("edit comment failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, newCommentResource(comment))
+	}
+}
+
+func NewApiPublicBlogCommentDelete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, ok := apiPathId(ctx, "bid")
+		if !ok {
+			return
+		}
+		cid, ok := apiPathId(ctx, "cid")
+		if !ok {
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+
+		err := database.DeletePublicBlogComment(bid, cid, loginUid)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("delete public blog comment failed", zap.Int("bid", bid), zap.Int("cid", cid), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("delete comment failed"))
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myblog/handler/apperr"
+	"myblog/handler/middleware"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newApiTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api/v1")
+	api.POST("/auth/login", NewApiLogin())
+	api.POST("/auth/token", NewApiToken())
+	api.GET("/blogs/:bid", middleware.Auth(), NewApiBlog())
+	api.GET("/public/blogs", NewApiPublicBlogs())
+	api.DELETE("/public/blogs/:bid/comments/:cid", middleware.Auth(), NewApiPublicBlogCommentDelete())
+	return router
+}
+
+func assertApiError(t *testing.T, writer *httptest.ResponseRecorder, status int, code, msg string) *apperr.Error {
+	t.Helper()
+	assert.Equal(t, status, writer.Code)
+	assert.Contains(t, writer.Header().Get("Content-Type"), "application/json")
+	body := &apperr.ErrorResponse{}
+	assert.NoError(t, sonic.Unmarshal(writer.Body.Bytes(), body))
+	if !assert.NotNil(t, body.Error) {
+		return &apperr.Error{}
+	}
+	assert.Equal(t, code, body.Error.Code)
+	assert.Equal(t, msg, body.Error.Message)
+	return body.Error
+}
+
+func TestApiAuthFailedReturnsJSON(t *testing.T) {
+	writer := httptest.NewRecorder()
+	newApiTestRouter().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/api/v1/blogs/1", nil))
+	assertApiError(t, writer, http.StatusUnauthorized, "auth_failed", "auth failed")
+}
+
+func TestApiInvalidPathId(t *testing.T) {
+	router := newApiTestRouter()
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/blogs/abc", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+	appErr := assertApiError(t, writer, http.StatusBadRequest, "invalid_parameter", "invalid bid")
+	assert.Equal(t, []apperr.FieldError{{Field: "bid", Rule: "gt", Message: "invalid bid"}}, appErr.Fields)
+
+	writer = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/api/v1/public/blogs/1/comments/0", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+	assertApiError(t, writer, http.StatusBadRequest, "invalid_parameter", "invalid cid")
+}
+
+func TestApiPublicBlogsInvalidQuery(t *testing.T) {
+	router := newApiTestRouter()
+	for target, msg := range map[string]string{
+		"/api/v1/public/blogs?limit=0":       "invalid limit",
+		"/api/v1/public/blogs?limit=1000":    "invalid limit",
+		"/api/v1/public/blogs?user_id=alice": "invalid user_id",
+	} {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, target, nil))
+		assertApiError(t, writer, http.StatusBadRequest, "invalid_parameter", msg)
+	}
+}
+
+func TestApiLoginInvalidParameter(t *testing.T) {
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"user":"alice","pass":"short"}`))
+	request.Header.Set("Content-Type", "application/json")
+	newApiTestRouter().ServeHTTP(writer, request)
+	appErr := assertApiError(t, writer, http.StatusBadRequest, "invalid_parameter", "invalid parameter")
+	assert.Equal(t, []apperr.FieldError{{Field: "pass", Rule: "len", Message: "pass must be 32 characters long"}}, appErr.Fields)
+}
+
+func TestApiTokenRequiresRefreshToken(t *testing.T) {
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/token", strings.NewReader(`{}`))
+	request.Header.Set("Content-Type", "application/json")
+	newApiTestRouter().ServeHTTP(writer, request)
+	appErr := assertApiError(t, writer, http.StatusBadRequest, "invalid_parameter", "invalid parameter")
+	assert.Equal(t, []apperr.FieldError{{Field: "refresh_token", Rule: "required", Message: "refresh_token is required"}}, appErr.Fields)
+}
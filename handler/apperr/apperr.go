@@ -0,0 +1,121 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error 统一的应用错误。Code 是稳定的机器可读错误码, Message 是给人看的说明,
+// Fields 说明哪些参数不合法, Detail 是特定错误附带的数据; 原始错误只写日志, 不返回给客户端
+type Error struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Detail  any          `json:"detail,omitempty"`
+	cause   error
+}
+
+// FieldError 单个参数的校验错误, Rule 与 binding tag 中的规则名一致, 如 required、gt
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse 错误响应的 JSON 结构
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+var (
+	ErrInvalidParameter   = New(http.StatusBadRequest, "invalid_parameter", "invalid parameter")
+	ErrInvalidCursor      = New(http.StatusBadRequest, "invalid_cursor", "invalid cursor")
+	ErrAuthFailed         = New(http.StatusUnauthorized, "auth_failed", "auth failed")
+	ErrLoginFailed        = New(http.StatusUnauthorized, "login_failed", "incorrect user or password")
+	ErrInvalidToken       = New(http.StatusUnauthorized, "invalid_refresh_token", "invalid refresh token")
+	ErrNoPermission       = New(http.StatusForbidden, "no_permission", "no permission")
+	ErrCommentEditExpired = New(http.StatusForbidden, "comment_edit_expired", "comment can no longer be edited")
+	ErrCommentsClosed     = New(http.StatusForbidden, "comments_closed", "comments are closed")
+	ErrNotFound           = New(http.StatusNotFound, "not_found", "not found")
+	ErrUserNotExist       = New(http.StatusNotFound, "user_not_exist", "user not exist")
+	ErrBlogNotExist       = New(http.StatusNotFound, "blog_not_exist", "blog not exist")
+	ErrPublicBlogNotExist = New(http.StatusNotFound, "public_blog_not_exist", "public blog not exist")
+	ErrCommentNotExist    = New(http.StatusNotFound, "comment_not_exist", "comment not exist")
+	ErrRevisionNotExist   = New(http.StatusNotFound, "revision_not_exist", "revision not exist")
+	ErrDraftNotExist      = New(http.StatusNotFound, "draft_not_exist", "draft not exist")
+	ErrFileNotExist       = New(http.StatusNotFound, "file_not_exist", "file not exist")
+	ErrUserExist          = New(http.StatusConflict, "user_exist", "user already exist")
+	ErrVersionConflict    = New(http.StatusConflict, "version_conflict", "blog has been modified since it was loaded")
+	ErrTooManyRequests    = New(http.StatusTooManyRequests, "too_many_requests", "too many requests")
+	ErrTooLarge           = New(http.StatusRequestEntityTooLarge, "too_large", "request too large")
+	ErrUnsupportedType    = New(http.StatusUnsupportedMediaType, "unsupported_type", "unsupported file type")
+	ErrVersionRequired    = New(http.StatusPreconditionRequired, "version_required", "version or If-Match header required")
+	ErrInternal           = New(http.StatusInternalServerError, "internal_error", "internal error")
+)
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 按错误码比较, 因此 WithMessage 等派生出的错误与原错误 errors.Is 成立
+func (e *Error) Is(target error) bool {
+	var other *Error
+	return errors.As(target, &other) && other.Code == e.Code
+}
+
+func (e *Error) clone() *Error {
+	copied := *e
+	copied.Fields = append([]FieldError(nil), e.Fields...)
+	return &copied
+}
+
+// WithMessage 返回错误码相同、说明不同的错误, 预定义的错误本身不会被修改
+func (e *Error) WithMessage(message string) *Error {
+	copied := e.clone()
+	copied.Message = message
+	return copied
+}
+
+// WithField 追加一个参数错误
+func (e *Error) WithField(field, rule, message string) *Error {
+	copied := e.clone()
+	copied.Fields = append(copied.Fields, FieldError{Field: field, Rule: rule, Message: message})
+	return copied
+}
+
+// WithDetail 附带返回给客户端的数据, 如版本冲突时服务端的当前内容
+func (e *Error) WithDetail(detail any) *Error {
+	copied := e.clone()
+	copied.Detail = detail
+	return copied
+}
+
+// Wrap 记录导致该错误的原始错误, 写响应时会记入日志
+func (e *Error) Wrap(cause error) *Error {
+	copied := e.clone()
+	copied.cause = cause
+	return copied
+}
+
+// InvalidField 单个参数不合法, message 同时作为整体说明
+func InvalidField(field, rule, message string) *Error {
+	return ErrInvalidParameter.WithMessage(message).WithField(field, rule, message)
+}
+
+// Internal 服务端错误, message 说明哪个操作失败; 原始错误由调用方连同上下文记录日志
+func Internal(message string) *Error {
+	return ErrInternal.WithMessage(message)
+}
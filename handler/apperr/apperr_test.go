@@ -0,0 +1,83 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"myblog/database"
+	"myblog/global"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrom(t *testing.T) {
+	wrapped := fmt.Errorf("load blog: %w", database.ErrBlogNotExist)
+	appErr := From(wrapped)
+	assert.Equal(t, http.StatusNotFound, appErr.Status)
+	assert.Equal(t, "blog_not_exist", appErr.Code)
+	assert.ErrorIs(t, appErr, database.ErrBlogNotExist)
+
+	appErr = From(errors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, appErr.Status)
+	assert.Equal(t, "internal_error", appErr.Code)
+	assert.False(t, IsClientError(appErr))
+
+	custom := ErrNoPermission.WithMessage("no permission to publish")
+	assert.Same(t, custom, From(custom))
+	assert.ErrorIs(t, custom, ErrNoPermission)
+	assert.Equal(t, "no permission", ErrNoPermission.Message)
+	assert.True(t, IsClientError(fmt.Errorf("delete: %w", database.ErrCommentNoPermission)))
+}
+
+func TestBind(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var request struct {
+		User string `form:"user" binding:"required"`
+		Pass string `form:"pass" binding:"len=32"`
+	}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/?pass=abc", nil)
+
+	appErr := Bind(ctx.ShouldBindQuery(&request))
+	assert.Equal(t, "invalid_parameter", appErr.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "user", Rule: "required", Message: "user is required"},
+		{Field: "pass", Rule: "len", Message: "pass must be 32 characters long"},
+	}, appErr.Fields)
+}
+
+func TestWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.LoadHTMLFiles(global.ProjectRootPath + "views/error.html")
+	router.GET("/blog/:bid", func(ctx *gin.Context) {
+		Write(ctx, InvalidField("bid", "int", "invalid blog id"))
+	})
+	router.GET("/api/v1/blogs/:bid", func(ctx *gin.Context) {
+		Write(ctx, database.ErrBlogNotExist)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blog/x", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"invalid_parameter","message":"invalid blog id",
+		"fields":[{"field":"bid","rule":"int","message":"invalid blog id"}]}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/blog/x", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "invalid blog id")
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/blogs/1", nil)
+	req.Header.Set("Accept", "text/html")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"blog_not_exist","message":"blog not exist"}}`, w.Body.String())
+}
@@ -0,0 +1,167 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"myblog/database"
+	"myblog/storage"
+	"myblog/util"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// sentinels 下层返回的哨兵错误与应用错误的对应关系, 按顺序匹配
+var sentinels = []struct {
+	err    error
+	appErr *Error
+}{
+	{database.ErrBlogNotExist, ErrBlogNotExist},
+	{database.ErrPublicBlogNotExist, ErrPublicBlogNotExist},
+	{database.ErrSlugNotExist, ErrPublicBlogNotExist},
+	{database.ErrCommentNotExist, ErrCommentNotExist},
+	{database.ErrCommentNoPermission, ErrNoPermission.WithMessage("no permission to delete comment")},
+	{database.ErrCommentEditDenied, ErrNoPermission.WithMessage("no permission to edit comment")},
+	{database.ErrCommentEditExpired, ErrCommentEditExpired},
+	{database.ErrCommentsClosed, ErrCommentsClosed},
+	{database.ErrInvalidCommentMode, InvalidField("mode", "oneof", "invalid comment mode")},
+	{database.ErrInvalidCommentStatus, InvalidField("status", "oneof", "invalid comment status")},
+	{database.ErrInvalidCommentContent, InvalidField("content", "len", "invalid comment content")},
+	{database.ErrInvalidDeleteComment, ErrInvalidParameter},
+	{database.ErrInvalidEditComment, ErrInvalidParameter},
+	{database.ErrInvalidParentComment, InvalidField("parent_id", "exists", "invalid parent comment")},
+	{database.ErrInvalidCommentSort, InvalidField("sort", "oneof", "invalid sort")},
+	{database.ErrInvalidReaction, InvalidField("kind", "oneof", "invalid reaction")},
+	{database.ErrInvalidBookmark, ErrInvalidParameter},
+	{database.ErrInvalidCursor, ErrInvalidCursor.WithField("cursor", "cursor", "invalid cursor")},
+	{database.ErrRevisionNotExist, ErrRevisionNotExist},
+	{database.ErrVersionConflict, ErrVersionConflict},
+	{storage.ErrNotExist, ErrFileNotExist},
+	{util.ErrDiffTooLarge, ErrTooLarge.WithMessage("revisions are too large to diff")},
+	{gorm.ErrRecordNotFound, ErrNotFound},
+}
+
+func init() {
+	// 校验错误中的字段名使用 form/json tag, 与客户端提交的参数名一致
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, key := range []string{"form", "json"} {
+				name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+				if name == "-" {
+					return ""
+				}
+				if len(name) > 0 {
+					return name
+				}
+			}
+			return field.Name
+		})
+	}
+}
+
+// From 把任意错误转换为应用错误, 无法识别的错误视为服务端错误
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel.err) {
+			return sentinel.appErr.Wrap(err)
+		}
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Bind 转换 ctx.ShouldBind 返回的错误, 校验失败时逐个列出不合法的字段
+func Bind(err error) *Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrTooLarge.Wrap(err)
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return ErrInvalidParameter.Wrap(err)
+	}
+	appErr := ErrInvalidParameter.Wrap(err)
+	for _, fieldErr := range validationErrors {
+		appErr = appErr.WithField(fieldErr.Field(), fieldErr.Tag(), fieldMessage(fieldErr))
+	}
+	return appErr
+}
+
+func fieldMessage(fieldErr validator.FieldError) string {
+	field, param := fieldErr.Field(), fieldErr.Param()
+	isString := fieldErr.Kind() == reflect.String
+	switch fieldErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "len":
+		if isString {
+			return fmt.Sprintf("%s must be %s characters long", field, param)
+		}
+		return fmt.Sprintf("%s must contain %s items", field, param)
+	case "min", "gte":
+		if isString {
+			return fmt.Sprintf("%s must be at least %s characters long", field, param)
+		}
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
+	case "max", "lte":
+		if isString {
+			return fmt.Sprintf("%s must be at most %s characters long", field, param)
+		}
+		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, param)
+	}
+	return field + " is invalid"
+}
+
+// wantsHTML 浏览器直接访问页面时返回错误页; /api 路由、ajax 请求和其他客户端返回 JSON
+func wantsHTML(ctx *gin.Context) bool {
+	if strings.HasPrefix(ctx.Request.URL.Path, "/api/") || ctx.GetHeader("X-Requested-With") == "XMLHttpRequest" {
+		return false
+	}
+	return ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// Write 写回错误响应并终止后续 handler。服务端错误如果带有原始错误会记入日志
+func Write(ctx *gin.Context, err error) {
+	appErr := From(err)
+	if appErr.Status >= http.StatusInternalServerError && appErr.cause != nil {
+		zap.L().Error("request failed",
+			zap.String("method", ctx.Request.Method),
+			zap.String("path", ctx.Request.URL.Path),
+			zap.String("code", appErr.Code),
+			zap.Error(appErr.cause),
+		)
+	}
+	if wantsHTML(ctx) {
+		ctx.HTML(appErr.Status, "error.html", gin.H{
+			"status":      appErr.Status,
+			"status_text": http.StatusText(appErr.Status),
+			"code":        appErr.Code,
+			"message":     appErr.Message,
+			"fields":      appErr.Fields,
+		})
+		ctx.Abort()
+		return
+	}
+	ctx.AbortWithStatusJSON(appErr.Status, &ErrorResponse{Error: appErr})
+}
+
+// IsClientError 错误能映射为 4xx 应用错误时返回 true, 此时可以直接交给 Write;
+// 否则调用方应记录日志并返回 Internal
+func IsClientError(err error) bool {
+	return From(err).Status < http.StatusInternalServerError
+}
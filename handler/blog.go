@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"myblog/database"
+	"myblog/handler/apperr"
 	"myblog/handler/middleware"
+	"myblog/util"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -15,19 +20,111 @@ func NewBlogList() gin.HandlerFunc {
 		param := ctx.Param("uid")
 		uid, err := strconv.Atoi(param)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid uid")
+			apperr.Write(ctx, apperr.InvalidField("uid", "int", "invalid user id"))
+			return
+		}
+		page, ok := parsePageQuery(ctx)
+		if !ok {
+			return
+		}
+		blogs, pageInfo, err := database.GetBlogByUserId(uid, page)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("get blog list failed", zap.Int("uid", uid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("get blog list failed"))
 			return
 		}
-		blogs := database.GetBlogByUserId(uid)
 		zap.L().Debug("get blog list", zap.Int("uid", uid), zap.Int("blog count", len(blogs)))
-		ctx.HTML(http.StatusOK, "blog_list.html", blogs)
+		bids := make([]int, 0, len(blogs))
+		for _, blog := range blogs {
+			bids = append(bids, blog.Id)
+		}
+		ctx.HTML(http.StatusOK, "blog_list.html", gin.H{
+			"blogs":    blogs,
+			"views":    database.GetBlogViewTotals(bids),
+			"total":    pageInfo.Total,
+			"next_url": pageURL(ctx, pageInfo.Limit, pageInfo.Next),
+			"prev_url": pageURL(ctx, pageInfo.Limit, pageInfo.Prev),
+		})
+	}
+}
+
+func renderPublicBlogList(ctx *gin.Context, query *database.PublicBlogQuery) {
+	page, ok := parsePageQuery(ctx)
+	if !ok {
+		return
+	}
+	query.PageQuery = *page
+	stamp, err := database.GetPublicBlogListStamp()
+	if err != nil {
+		apperr.Write(ctx, apperr.Internal("get public blog list failed"))
+		return
+	}
+	reactionStamp, err := database.GetBlogReactionStamp()
+	if err != nil {
+		apperr.Write(ctx, apperr.Internal("get public blog list failed"))
+		return
+	}
+	// 各个列表页的 URL 不同, 缓存本身按 URL 区分, ETag 只需反映公开博客和表态整体的变化;
+	// Last-Modified 取博客与表态中较晚的时间, 取消表态不会推进时间, 由 ETag 中的表态数反映
+	lastModified := stamp.LastModified
+	if reactionStamp.LastModified.After(lastModified) {
+		lastModified = reactionStamp.LastModified
+	}
+	validator := newCacheValidator(cacheRoutePublicBlogList, lastModified, stamp.Count, stamp.LastModified.UnixNano(),
+		reactionStamp.Count, reactionStamp.LastModified.UnixNano())
+	if validator.notModified(ctx) {
+		return
+	}
+
+	blogs, pageInfo, err := database.GetPublicBlogList(query)
+	if err != nil {
+		if apperr.IsClientError(err) {
+			apperr.Write(ctx, err)
+			return
+		}
+		zap.L().Error("get public blog list failed", zap.Error(err))
+		apperr.Write(ctx, apperr.Internal("get public blog list failed"))
+		return
+	}
+	bids := make([]int, 0, len(blogs))
+	for _, blog := range blogs {
+		bids = append(bids, blog.Id)
 	}
+	validator.writeHeaders(ctx)
+	ctx.HTML(http.StatusOK, "public_blog_list.html", gin.H{
+		"blogs":      blogs,
+		"reactions":  database.GetBlogReactionCounts(bids),
+		"total":      pageInfo.Total,
+		"next_url":   pageURL(ctx, pageInfo.Limit, pageInfo.Next),
+		"prev_url":   pageURL(ctx, pageInfo.Limit, pageInfo.Prev),
+		"tag":        query.Tag,
+		"category":   query.Category,
+		"tags":       database.GetTagCloud(),
+		"categories": database.GetCategoryCounts(),
+	})
 }
 
 func NewPublicBlogList() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		blogs := database.GetPublicBlogList()
-		ctx.HTML(http.StatusOK, "public_blog_list.html", blogs)
+		renderPublicBlogList(ctx, &database.PublicBlogQuery{
+			Tag:      strings.TrimSpace(ctx.Query("tag")),
+			Category: strings.TrimSpace(ctx.Query("category")),
+		})
+	}
+}
+
+func NewPublicBlogTag() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tag := strings.TrimSpace(ctx.Param("tag"))
+		if len(tag) == 0 {
+			apperr.Write(ctx, apperr.InvalidField("tag", "required", "invalid tag"))
+			return
+		}
+		renderPublicBlogList(ctx, &database.PublicBlogQuery{Tag: tag})
 	}
 }
 
@@ -36,21 +133,30 @@ func NewBlogDetail() gin.HandlerFunc {
 		param := ctx.Param("bid")
 		bid, err := strconv.Atoi(param)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid blog id")
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
 			return
 		}
 		blog := database.GetBlogById(bid)
 		if blog == nil {
-			ctx.String(http.StatusNotFound, "blog not exist")
+			apperr.Write(ctx, apperr.ErrBlogNotExist)
 			return
 		}
 		zap.L().Debug("get blog detail", zap.String("article", blog.Article))
+		schedule := database.GetBlogSchedule(blog.Id)
+		ctx.Header("ETag", blogETag(blog.Version))
 		ctx.HTML(http.StatusOK, "blog.html", gin.H{
-			"title":       blog.Title,
-			"article":     blog.Article,
-			"bid":         blog.Id,
-			"update_time": blog.UpdateTime.Format("2006-01-02 15:04:05"),
-			"is_public":   database.IsBlogPublic(blog.Id),
+			"title":        blog.Title,
+			"article":      blog.Article,
+			"article_html": util.RenderMarkdownCached(blog.Id, blog.UpdateTime, blog.Article),
+			"category":     blog.Category,
+			"tags":         strings.Join(database.GetBlogTags(blog.Id), ", "),
+			"bid":          blog.Id,
+			"uid":          blog.UserId,
+			"version":      blog.Version,
+			"update_time":  blog.UpdateTime.Format("2006-01-02 15:04:05"),
+			"is_public":    database.IsBlogPublic(blog.Id),
+			"publish_at":   scheduleTimeValue(schedule, true),
+			"unpublish_at": scheduleTimeValue(schedule, false),
 		})
 	}
 }
@@ -60,37 +166,147 @@ func NewPublicBlogDetail() gin.HandlerFunc {
 		param := ctx.Param("bid")
 		bid, err := strconv.Atoi(param)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid blog id")
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		validator, ok := publicBlogValidator(ctx, bid)
+		if !ok {
+			return
+		}
+		recordBlogView(ctx, bid) // 304 也是一次阅读
+		if validator.notModified(ctx) {
 			return
 		}
 		blog := database.GetPublicBlogById(bid)
 		if blog == nil {
-			ctx.String(http.StatusNotFound, "public blog not exist")
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
 			return
 		}
-		ctx.HTML(http.StatusOK, "blog_public.html", gin.H{
-			"title":       blog.Title,
-			"article":     blog.Article,
-			"bid":         blog.Id,
-			"user_name":   blog.UserName,
-			"update_time": blog.UpdateTime.Format("2006-01-02 15:04:05"),
-		})
+		validator.writeHeaders(ctx)
+		renderPublicBlog(ctx, blog)
+	}
+}
+
+// publicBlogValidator 公开博客详情页的缓存校验信息, 博客未公开或查询出错时已写回响应并返回 false
+func publicBlogValidator(ctx *gin.Context, bid int) (*cacheValidator, bool) {
+	stamp, err := database.GetPublicBlogStamp(bid)
+	if err != nil {
+		if apperr.IsClientError(err) {
+			apperr.Write(ctx, err)
+			return nil, false
+		}
+		apperr.Write(ctx, apperr.Internal("get public blog failed"))
+		return nil, false
 	}
+	// 页面中的 canonical_url 可能取自请求的 Host
+	return newCacheValidator(cacheRoutePublicBlog, stamp.LastModified, bid, stamp.Version, stamp.LastModified.UnixNano(), siteBaseURL(ctx)), true
+}
+
+func renderPublicBlog(ctx *gin.Context, blog *database.PublicBlogDetail) {
+	ctx.HTML(http.StatusOK, "blog_public.html", gin.H{
+		"title":         blog.Title,
+		"article":       util.RenderMarkdownCached(blog.Id, blog.UpdateTime, blog.Article),
+		"bid":           blog.Id,
+		"category":      blog.Category,
+		"tags":          blog.Tags,
+		"user_name":     blog.UserName,
+		"update_time":   blog.UpdateTime.Format("2006-01-02 15:04:05"),
+		"canonical_url": siteBaseURL(ctx) + publicBlogPath(blog.Id, blog.Slug),
+		"reactions":     database.Reactions,
+	})
 }
 
 type UpdateRequest struct {
-	BlogId  int    `json:"bid" form:"bid" binding:"required,gt=0"`
-	Title   string `json:"title" form:"title" binding:"required"`
-	Article string `json:"article" form:"article" binding:"required"`
+	BlogId   int     `json:"bid" form:"bid" binding:"required,gt=0"`
+	Title    string  `json:"title" form:"title" binding:"required"`
+	Article  string  `json:"article" form:"article" binding:"required"`
+	Category *string `json:"category" form:"category"` // 不传表示保持不变
+	Tags     *string `json:"tags" form:"tags"`         // 逗号分隔, 不传表示保持不变
+	Version  int     `json:"version" form:"version"`   // 编辑时基于的版本, 也可以通过 If-Match 请求头传递
 }
 
 type CreateRequest struct {
-	Title   string `json:"title" form:"title" binding:"required"`
-	Article string `json:"article" form:"article" binding:"required"`
+	Title    string `json:"title" form:"title" binding:"required"`
+	Article  string `json:"article" form:"article" binding:"required"`
+	Category string `json:"category" form:"category"`
+	Tags     string `json:"tags" form:"tags"` // 逗号分隔
 }
 
 type PublishRequest struct {
-	BlogId int `json:"bid" form:"bid" binding:"required,gt=0"`
+	BlogId      int    `json:"bid" form:"bid" binding:"required,gt=0"`
+	PublishAt   string `json:"publish_at" form:"publish_at"`     // 为空或已过去时立即发布
+	UnpublishAt string `json:"unpublish_at" form:"unpublish_at"` // 为空时不自动取消发布
+}
+
+var scheduleTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339}
+
+// parsePublishSchedule 解析并校验发布计划, 返回的 publishAt 为 nil 表示立即发布
+func parsePublishSchedule(publishAtValue, unpublishAtValue string) (publishAt, unpublishAt *time.Time, err error) {
+	publishAt, err = parseScheduleTime(publishAtValue)
+	if err != nil {
+		return nil, nil, apperr.InvalidField("publish_at", "datetime", "invalid publish_at")
+	}
+	unpublishAt, err = parseScheduleTime(unpublishAtValue)
+	if err != nil {
+		return nil, nil, apperr.InvalidField("unpublish_at", "datetime", "invalid unpublish_at")
+	}
+
+	now := time.Now()
+	if publishAt != nil && !publishAt.After(now) {
+		publishAt = nil
+	}
+	if unpublishAt != nil {
+		start := now
+		if publishAt != nil {
+			start = *publishAt
+		}
+		if !unpublishAt.After(start) {
+			return nil, nil, apperr.InvalidField("unpublish_at", "gtfield", "unpublish_at must be later than publish_at")
+		}
+	}
+	return publishAt, unpublishAt, nil
+}
+
+// applyPublish publishAt 为空时立即发布, 否则只保存计划。
+// 每次发布都会覆盖旧的计划, 立即发布且不带 unpublishAt 时即清除计划
+func applyPublish(bid, uid int, publishAt, unpublishAt *time.Time) error {
+	if publishAt == nil {
+		if err := database.PublishBlog(bid, uid); err != nil {
+			return err
+		}
+	}
+	return database.SetBlogSchedule(bid, uid, publishAt, unpublishAt)
+}
+
+// scheduleTimeValue 把发布计划时间格式化为 datetime-local 输入框的取值
+func scheduleTimeValue(schedule *database.BlogSchedule, publish bool) string {
+	if schedule == nil {
+		return ""
+	}
+	t := schedule.UnpublishAt
+	if publish {
+		t = schedule.PublishAt
+	}
+	if t == nil {
+		return ""
+	}
+	return t.In(time.Local).Format("2006-01-02T15:04")
+}
+
+// parseScheduleTime 解析发布计划时间, 不带时区的时间按服务器本地时区处理; 空字符串返回 nil
+func parseScheduleTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	var err error
+	for _, layout := range scheduleTimeLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, err
 }
 
 func NewBlogUpdate() gin.HandlerFunc {
@@ -100,45 +316,61 @@ func NewBlogUpdate() gin.HandlerFunc {
 		err := ctx.ShouldBind(request)
 		if err != nil {
 			zap.L().Error("invalid update blog request", zap.Error(err))
-			ctx.String(http.StatusBadRequest, "invalid parameter")
+			apperr.Write(ctx, apperr.Bind(err))
 			return
 		}
 		bid := request.BlogId
 		title := request.Title
 		article := request.Article
+		version, ok := expectedBlogVersion(ctx, request.Version)
+		if !ok {
+			return
+		}
 
 		blog := database.GetBlogById(bid)
 		if blog == nil {
-			ctx.String(http.StatusBadRequest, "blog not exist")
+			apperr.Write(ctx, apperr.ErrBlogNotExist)
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
 		if blog.UserId != loginUid {
-			ctx.String(http.StatusForbidden, "no permission to update")
+			apperr.Write(ctx, apperr.ErrNoPermission.WithMessage("no permission to update"))
 			return
 		}
 		updateData := &database.Blog{
 			Id:      bid,
+			UserId:  loginUid,
 			Title:   title,
 			Article: article,
+			Version: version,
+		}
+		meta := &database.BlogMeta{Category: request.Category}
+		if request.Tags != nil {
+			meta.Tags = database.ParseTags(*request.Tags)
+		}
+		err = database.UpdateBlog(updateData, meta)
+		if errors.Is(err, database.ErrVersionConflict) {
+			writeVersionConflict(ctx, bid)
+			return
 		}
-		err = database.UpdateBlog(updateData)
 		if err != nil {
 			zap.L().Error("update blog failed", zap.Int("bid", bid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "update blog failed")
+			apperr.Write(ctx, apperr.Internal("update blog failed"))
 			return
 		}
+		database.DeleteDraft(loginUid, bid) // 已正式保存, 草稿不再需要
+		ctx.Header("ETag", blogETag(updateData.Version))
 		ctx.String(http.StatusOK, "update blog success")
 	}
 }
@@ -149,18 +381,18 @@ func NewBlogCreate() gin.HandlerFunc {
 		err := ctx.ShouldBind(request)
 		if err != nil {
 			zap.L().Error("invalid create blog request", zap.Error(err))
-			ctx.String(http.StatusBadRequest, "invalid parameter")
+			apperr.Write(ctx, apperr.Bind(err))
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
@@ -169,11 +401,15 @@ func NewBlogCreate() gin.HandlerFunc {
 			Title:   request.Title,
 			Article: request.Article,
 		}
+		meta := &database.BlogMeta{
+			Category: &request.Category,
+			Tags:     database.ParseTags(request.Tags),
+		}
 
-		err = database.CreateBlog(blog)
+		err = database.CreateBlog(blog, meta)
 		if err != nil {
 			zap.L().Error("create blog failed", zap.Int("uid", loginUid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "create blog failed")
+			apperr.Write(ctx, apperr.Internal("create blog failed"))
 			return
 		}
 
@@ -185,34 +421,43 @@ func NewBlogPublish() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		request := &PublishRequest{}
 		if err := ctx.ShouldBind(request); err != nil {
-			ctx.String(http.StatusBadRequest, "invalid parameter")
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		publishAt, unpublishAt, err := parsePublishSchedule(request.PublishAt, request.UnpublishAt)
+		if err != nil {
+			apperr.Write(ctx, err)
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
 		blog := database.GetBlogById(request.BlogId)
 		if blog == nil {
-			ctx.String(http.StatusBadRequest, "blog not exist")
+			apperr.Write(ctx, apperr.ErrBlogNotExist)
 			return
 		}
 		if blog.UserId != loginUid {
-			ctx.String(http.StatusForbidden, "no permission to publish")
+			apperr.Write(ctx, apperr.ErrNoPermission.WithMessage("no permission to publish"))
 			return
 		}
 
-		if err := database.PublishBlog(request.BlogId, loginUid); err != nil {
+		if err := applyPublish(request.BlogId, loginUid, publishAt, unpublishAt); err != nil {
 			zap.L().Error("publish blog failed", zap.Int("bid", request.BlogId), zap.Int("uid", loginUid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "publish blog failed")
+			apperr.Write(ctx, apperr.Internal("publish blog failed"))
+			return
+		}
+		if publishAt != nil {
+			ctx.String(http.StatusOK, "publish blog scheduled")
 			return
 		}
 		ctx.String(http.StatusOK, "publish blog success")
@@ -223,36 +468,40 @@ func NewBlogUnpublish() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		request := &PublishRequest{}
 		if err := ctx.ShouldBind(request); err != nil {
-			ctx.String(http.StatusBadRequest, "invalid parameter")
+			apperr.Write(ctx, apperr.Bind(err))
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
 		blog := database.GetBlogById(request.BlogId)
 		if blog == nil {
-			ctx.String(http.StatusBadRequest, "blog not exist")
+			apperr.Write(ctx, apperr.ErrBlogNotExist)
 			return
 		}
 		if blog.UserId != loginUid {
-			ctx.String(http.StatusForbidden, "no permission to unpublish")
+			apperr.Write(ctx, apperr.ErrNoPermission.WithMessage("no permission to unpublish"))
 			return
 		}
 
 		if err := database.UnpublishBlog(request.BlogId, loginUid); err != nil {
 			zap.L().Error("unpublish blog failed", zap.Int("bid", request.BlogId), zap.Int("uid", loginUid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "unpublish blog failed")
+			apperr.Write(ctx, apperr.Internal("unpublish blog failed"))
 			return
 		}
+		// 手动取消发布的同时取消尚未执行的发布计划, 避免之后又被自动公开
+		if err := database.DeleteBlogSchedule(request.BlogId); err != nil {
+			zap.L().Error("delete blog schedule failed", zap.Int("bid", request.BlogId), zap.Error(err))
+		}
 		ctx.String(http.StatusOK, "unpublish blog success")
 	}
 }
@@ -262,13 +511,13 @@ func BlogBelong(ctx *gin.Context) {
 	token := ctx.Query("token")
 	bid, err := strconv.Atoi(bids)
 	if err != nil {
-		ctx.String(http.StatusBadRequest, "invalid blog id")
+		apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
 		return
 	}
 
 	blog := database.GetBlogById(bid)
 	if blog == nil {
-		ctx.String(http.StatusBadRequest, "blog id not exists")
+		apperr.Write(ctx, apperr.ErrBlogNotExist)
 		return
 	}
 
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type BookmarkResource struct {
+	PublicBlogResource
+	BookmarkTime time.Time `json:"bookmark_time"`
+}
+
+// NewBookmarkPage 收藏页面, 列表由页面带 auth_token 请求 /bookmarks/list 获取
+func NewBookmarkPage() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.HTML(http.StatusOK, "bookmarks.html", gin.H{})
+	}
+}
+
+// NewBookmarkList 当前用户收藏的公开博客, 按收藏时间倒序分页; 已取消发布的博客不显示
+func NewBookmarkList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+		page, ok := parsePageQuery(ctx)
+		if !ok {
+			return
+		}
+
+		blogs, pageInfo, err := database.GetBookmarkedBlogs(loginUid, page)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get bookmarks failed"))
+			return
+		}
+		items := make([]*BookmarkResource, 0, len(blogs))
+		for _, blog := range blogs {
+			items = append(items, &BookmarkResource{
+				PublicBlogResource: *newPublicBlogResource(&blog.PublicBlogPreview),
+				BookmarkTime:       blog.BookmarkTime,
+			})
+		}
+		ctx.JSON(http.StatusOK, &ListResponse[*BookmarkResource]{Items: items, Page: pageInfo})
+	}
+}
+
+// NewBookmarkStatus 当前用户是否收藏了该博客
+func NewBookmarkStatus() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+
+		bookmarked, err := database.IsBookmarked(loginUid, bid)
+		if err != nil {
+			apperr.Write(ctx, apperr.Internal("get bookmark failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"bookmarked": bookmarked})
+	}
+}
+
+// NewBookmarkSet 收藏 (PUT) 或取消收藏 (DELETE), 重复请求的结果相同
+func NewBookmarkSet(add bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+
+		if add {
+			err = database.AddBookmark(loginUid, bid)
+		} else {
+			err = database.RemoveBookmark(loginUid, bid)
+		}
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("set bookmark failed", zap.Int("bid", bid), zap.Int("uid", loginUid), zap.Bool("add", add), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("set bookmark failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"bookmarked": add})
+	}
+}
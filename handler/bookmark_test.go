@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBookmarkTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/bookmarks/list", middleware.Auth(), NewBookmarkList())
+	router.GET("/blog/public/:bid/bookmark", middleware.Auth(), NewBookmarkStatus())
+	router.PUT("/blog/public/:bid/bookmark", middleware.Auth(), NewBookmarkSet(true))
+	router.DELETE("/blog/public/:bid/bookmark", middleware.Auth(), NewBookmarkSet(false))
+	return router
+}
+
+func TestBookmarkRequiresAuth(t *testing.T) {
+	router := newBookmarkTestRouter()
+	for _, request := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/bookmarks/list", nil),
+		httptest.NewRequest(http.MethodGet, "/blog/public/1/bookmark", nil),
+		httptest.NewRequest(http.MethodPut, "/blog/public/1/bookmark", nil),
+		httptest.NewRequest(http.MethodDelete, "/blog/public/1/bookmark", nil),
+	} {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, request)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code, request.Method+" "+request.URL.Path)
+	}
+}
+
+func TestBookmarkInvalidBid(t *testing.T) {
+	router := newBookmarkTestRouter()
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(method, "/blog/public/abc/bookmark", nil)
+		request.Header.Set("auth_token", newCommentTestToken(t, 1))
+		router.ServeHTTP(writer, request)
+		assert.Equal(t, http.StatusBadRequest, writer.Code, method)
+		assert.Contains(t, writer.Body.String(), "invalid blog id")
+	}
+}
+
+func TestBookmarkListInvalidLimit(t *testing.T) {
+	router := newBookmarkTestRouter()
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/bookmarks/list?limit=0", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid limit")
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 公开页面的 HTTP 条件缓存: 先用 database 中的 ContentStamp 生成 ETag 和 Last-Modified,
+// 客户端缓存仍然有效时直接返回 304, 不再查询完整内容和渲染模板
+
+const (
+	cacheRoutePublicBlog         = "public_blog"
+	cacheRoutePublicBlogList     = "public_blog_list"
+	cacheRoutePublicBlogComments = "public_blog_comments"
+
+	defaultCacheControl = "no-cache" // 可以缓存, 但每次使用前都要向服务端确认
+)
+
+// buildVersion 构建时通过 -ldflags "-X myblog/handler.buildVersion=<版本>" 注入的版本号
+var buildVersion string
+
+// cacheSeed 参与所有 ETag 的计算, 新版本发布后模板可能变化, 旧的缓存随之失效。
+// 依次取注入的版本号、构建信息中的 git 提交和模板文件内容的摘要, 同一版本的多个副本、
+// 重启前后都得到相同的值 (Docker 构建时没有 .git, 不会带上提交信息)
+var cacheSeed = func() string {
+	if len(buildVersion) > 0 {
+		return buildVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return templateDigest("views")
+}()
+
+// templateDigest dir 下各个 html 模板内容的摘要, 读取失败的文件不参与计算
+func templateDigest(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+	sort.Strings(files)
+	hash := sha1.New()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(hash, "%s|%d|", filepath.Base(file), len(content))
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cacheValidator 一个响应的缓存校验信息
+type cacheValidator struct {
+	route        string
+	etag         string
+	lastModified time.Time
+}
+
+// newCacheValidator 由页面类型和决定内容的各个值生成强 ETag
+func newCacheValidator(route string, lastModified time.Time, parts ...any) *cacheValidator {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s|%s", cacheSeed, route)
+	for _, part := range parts {
+		fmt.Fprintf(hash, "|%v", part)
+	}
+	return &cacheValidator{
+		route:        route,
+		etag:         `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+		lastModified: lastModified,
+	}
+}
+
+// cacheControl 路由的缓存策略, 取自配置 cache.<route>, 未配置时为 no-cache
+func cacheControl(route string) string {
+	if value := blogConfig.GetString("cache." + route); len(value) > 0 {
+		return value
+	}
+	return defaultCacheControl
+}
+
+// writeHeaders 写入 ETag、Last-Modified 和 Cache-Control, 在确定返回 200 之前调用,
+// 避免错误响应带上缓存策略
+func (v *cacheValidator) writeHeaders(ctx *gin.Context) {
+	ctx.Header("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		ctx.Header("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+	ctx.Header("Cache-Control", cacheControl(v.route))
+}
+
+// notModified 客户端缓存仍然有效时写回 304 并返回 true
+func (v *cacheValidator) notModified(ctx *gin.Context) bool {
+	if !notModified(ctx, v.etag, v.lastModified) {
+		return false
+	}
+	v.writeHeaders(ctx)
+	ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// notModified 按 If-None-Match 优先、If-Modified-Since 其次判断客户端缓存是否仍然有效
+func notModified(ctx *gin.Context, etag string, lastModified time.Time) bool {
+	if match := ctx.GetHeader("If-None-Match"); len(match) > 0 {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if since := ctx.GetHeader("If-Modified-Since"); len(since) > 0 && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
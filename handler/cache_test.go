@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCacheTestRouter(validator *cacheValidator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/page", func(ctx *gin.Context) {
+		if validator.notModified(ctx) {
+			return
+		}
+		validator.writeHeaders(ctx)
+		ctx.String(http.StatusOK, "content")
+	})
+	return router
+}
+
+func TestCacheValidator(t *testing.T) {
+	lastModified := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	validator := newCacheValidator(cacheRoutePublicBlog, lastModified, 1, 2)
+	router := newCacheTestRouter(validator)
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/page", nil))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	etag := writer.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{40}"$`, etag)
+	assert.Equal(t, "Fri, 02 Jan 2026 03:04:05 GMT", writer.Header().Get("Last-Modified"))
+	assert.Equal(t, cacheControl(cacheRoutePublicBlog), writer.Header().Get("Cache-Control"))
+
+	writer = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/page", nil)
+	request.Header.Set("If-None-Match", `"other", `+etag)
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusNotModified, writer.Code)
+	assert.Empty(t, writer.Body.String())
+	assert.Equal(t, etag, writer.Header().Get("ETag"))
+
+	// If-None-Match 优先于 If-Modified-Since
+	writer = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/page", nil)
+	request.Header.Set("If-None-Match", `"other"`)
+	request.Header.Set("If-Modified-Since", "Fri, 02 Jan 2026 03:04:05 GMT")
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/page", nil)
+	request.Header.Set("If-Modified-Since", "Fri, 02 Jan 2026 03:04:05 GMT")
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusNotModified, writer.Code)
+
+	writer = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/page", nil)
+	request.Header.Set("If-Modified-Since", "Fri, 02 Jan 2026 03:04:04 GMT")
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusOK, writer.Code)
+}
+
+func TestCacheValidatorETag(t *testing.T) {
+	lastModified := time.Now()
+	etag := newCacheValidator(cacheRoutePublicBlog, lastModified, 1, 2).etag
+	assert.Equal(t, etag, newCacheValidator(cacheRoutePublicBlog, lastModified, 1, 2).etag)
+	assert.NotEqual(t, etag, newCacheValidator(cacheRoutePublicBlog, lastModified, 1, 3).etag)
+	assert.NotEqual(t, etag, newCacheValidator(cacheRoutePublicBlogComments, lastModified, 1, 2).etag)
+}
+
+func TestCacheControlDefault(t *testing.T) {
+	assert.Equal(t, defaultCacheControl, cacheControl("not_configured"))
+}
+
+func TestTemplateDigest(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.html"), []byte("<p>a</p>"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.html"), []byte("<p>b</p>"), 0o644))
+
+	// 同样的模板每次得到同样的摘要, 与启动时间无关
+	digest := templateDigest(dir)
+	assert.Equal(t, digest, templateDigest(dir))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.html"), []byte("<p>b2</p>"), 0o644))
+	assert.NotEqual(t, digest, templateDigest(dir))
+}
@@ -1,46 +1,199 @@
 package handler
 
 import (
-	"errors"
 	"myblog/database"
+	"myblog/handler/apperr"
 	"net/http"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
 type CreateCommentRequest struct {
+	Content  string `json:"content" form:"content" binding:"required"`
+	ParentId int    `json:"parent_id" form:"parent_id" binding:"gte=0"` // 回复的评论 id, 不传表示直接评论博客
+}
+
+// UpdateCommentRequest 修改评论, 只能修改内容
+type UpdateCommentRequest struct {
 	Content string `json:"content" form:"content" binding:"required"`
 }
 
+const (
+	defaultCommentMaxDepth   = 5
+	defaultCommentEditWindow = 15 * time.Minute
+)
+
+// commentMaxDepth 评论树的最大层数, 取自配置 comment.max_depth
+func commentMaxDepth() int {
+	if depth := blogConfig.GetInt("comment.max_depth"); depth > 0 {
+		return depth
+	}
+	return defaultCommentMaxDepth
+}
+
+// commentEditWindow 发表后可以修改评论的时长, 取自配置 comment.edit_window, 配置为 0 时不限制
+func commentEditWindow() time.Duration {
+	if !blogConfig.IsSet("comment.edit_window") {
+		return defaultCommentEditWindow
+	}
+	return blogConfig.GetDuration("comment.edit_window")
+}
+
+type commentNode struct {
+	comment *database.PublicBlogCommentItem
+	replyTo string // 被回复的评论作者, 超出最大层数被提升的回复据此显示回复的对象
+	replies []*commentNode
+}
+
+// buildCommentTree 把 GetPublicBlogComments 返回的列表组织成树: 顶层评论保持列表中的顺序, 回复按时间先后排列。
+// 超过 maxDepth 层的回复挂到第 maxDepth-1 层的祖先下, 与被回复的评论同级显示;
+// 找不到上级的回复作为顶层评论显示
+func buildCommentTree(comments []*database.PublicBlogCommentItem, maxDepth int) []*commentNode {
+	nodes := make(map[int]*commentNode, len(comments))
+	for _, comment := range comments {
+		nodes[comment.Id] = &commentNode{comment: comment}
+	}
+	depths := make(map[int]int, len(comments))
+	var depthOf func(node *commentNode) int
+	depthOf = func(node *commentNode) int {
+		if depth, ok := depths[node.comment.Id]; ok {
+			return depth
+		}
+		depth := 1
+		if parent, ok := nodes[node.comment.ParentId]; ok && parent.comment.Id < node.comment.Id {
+			depth = depthOf(parent) + 1
+		}
+		depths[node.comment.Id] = depth
+		return depth
+	}
+
+	roots := make([]*commentNode, 0, len(comments))
+	for _, comment := range comments {
+		node := nodes[comment.Id]
+		depth := depthOf(node)
+		if depth == 1 {
+			roots = append(roots, node)
+			continue
+		}
+		parent := nodes[node.comment.ParentId]
+		if !parent.comment.Deleted {
+			node.replyTo = parent.comment.UserName
+		}
+		for ; depth > maxDepth; depth-- {
+			parent = nodes[parent.comment.ParentId]
+		}
+		if parent == nil {
+			roots = append(roots, node)
+			continue
+		}
+		parent.replies = append(parent.replies, node)
+	}
+	// 提升上来的回复与原有的回复按时间合并
+	for _, node := range nodes {
+		slices.SortFunc(node.replies, func(a, b *commentNode) int {
+			if c := a.comment.CreateTime.Compare(b.comment.CreateTime); c != 0 {
+				return c
+			}
+			return a.comment.Id - b.comment.Id
+		})
+	}
+	return roots
+}
+
+func (node *commentNode) json() gin.H {
+	replies := make([]gin.H, 0, len(node.replies))
+	for _, reply := range node.replies {
+		replies = append(replies, reply.json())
+	}
+	comment := node.comment
+	result := gin.H{
+		"id":          comment.Id,
+		"parent_id":   comment.ParentId,
+		"user_name":   comment.UserName,
+		"content":     comment.Content,
+		"deleted":     comment.Deleted,
+		"status":      comment.Status,
+		"reply_to":    node.replyTo,
+		"create_time": comment.CreateTime.Format("2006-01-02 15:04:05"),
+		"edited":      nil,
+		"replies":     replies,
+	}
+	if comment.EditTime != nil {
+		result["edited"] = comment.EditTime.Format("2006-01-02 15:04:05")
+	}
+	if comment.Deleted {
+		result["user_name"] = ""
+		result["edited"] = nil
+	}
+	return result
+}
+
+// parseCommentQuery 读取评论列表的 limit、cursor 和 sort 参数, 不合法时已写回 400
+func parseCommentQuery(ctx *gin.Context) (*database.PublicBlogCommentQuery, bool) {
+	page, ok := parsePageQuery(ctx)
+	if !ok {
+		return nil, false
+	}
+	sort := ctx.DefaultQuery("sort", database.CommentSortNewest)
+	if sort != database.CommentSortNewest && sort != database.CommentSortOldest {
+		apperr.Write(ctx, apperr.InvalidField("sort", "oneof", "invalid sort"))
+		return nil, false
+	}
+	return &database.PublicBlogCommentQuery{PageQuery: *page, Sort: sort}, true
+}
+
 func NewPublicBlogComments() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		param := ctx.Param("bid")
 		bid, err := strconv.Atoi(param)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid blog id")
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		query, ok := parseCommentQuery(ctx)
+		if !ok {
 			return
 		}
 
-		blog := database.GetPublicBlogById(bid)
-		if blog == nil {
-			ctx.String(http.StatusNotFound, "public blog not exist")
+		if _, err := database.GetPublicBlogStamp(bid); err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get comments failed"))
+			return
+		}
+		stamp, err := database.GetPublicBlogCommentsStamp(bid)
+		if err != nil {
+			apperr.Write(ctx, apperr.Internal("get comments failed"))
+			return
+		}
+		validator := newCacheValidator(cacheRoutePublicBlogComments, stamp.LastModified, bid, stamp.Count, stamp.LastModified.UnixNano())
+		if validator.notModified(ctx) {
 			return
 		}
 
-		comments := database.GetPublicBlogComments(bid)
-		result := make([]gin.H, 0, len(comments))
-		for _, comment := range comments {
-			result = append(result, gin.H{
-				"id":          comment.Id,
-				"user_name":   comment.UserName,
-				"content":     comment.Content,
-				"create_time": comment.CreateTime.Format("2006-01-02 15:04:05"),
-			})
+		comments, pageInfo, err := database.GetPublicBlogComments(bid, query)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get comments failed"))
+			return
+		}
+		tree := buildCommentTree(comments, commentMaxDepth())
+		items := make([]gin.H, 0, len(tree))
+		for _, node := range tree {
+			items = append(items, node.json())
 		}
 
-		ctx.JSON(http.StatusOK, result)
+		validator.writeHeaders(ctx)
+		ctx.JSON(http.StatusOK, &ListResponse[gin.H]{Items: items, Page: pageInfo})
 	}
 }
 
@@ -49,54 +202,90 @@ func NewPublicBlogCommentCreate() gin.HandlerFunc {
 		param := ctx.Param("bid")
 		bid, err := strconv.Atoi(param)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid blog id")
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
 			return
 		}
 
 		request := &CreateCommentRequest{}
 		if err := ctx.ShouldBind(request); err != nil {
-			ctx.String(http.StatusBadRequest, "invalid parameter")
+			apperr.Write(ctx, apperr.Bind(err))
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
-		err = database.CreatePublicBlogComment(bid, loginUid, request.Content)
+		policy := loadCommentSpamPolicy()
+		if err := policy.checkCreate(ctx, loginUid, request.Content); err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		comment, err := database.ReplyPublicBlogComment(bid, loginUid, request.ParentId, request.Content)
 		if err != nil {
-			if errors.Is(err, database.ErrInvalidCommentContent) {
-				ctx.String(http.StatusBadRequest, "invalid parameter")
-				return
-			}
-			if errors.Is(err, database.ErrPublicBlogNotExist) {
-				ctx.String(http.StatusNotFound, "public blog not exist")
+			policy.release(loginUid, request.Content)
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
 				return
 			}
 			zap.L().Error("create public blog comment failed", zap.Int("bid", bid), zap.Int("uid", loginUid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "create comment failed")
+			apperr.Write(ctx, apperr.Internal("create comment failed"))
 			return
 		}
 
-		comments := database.GetPublicBlogComments(bid)
-		if len(comments) == 0 {
-			ctx.JSON(http.StatusOK, gin.H{"message": "create comment success"})
+		ctx.JSON(http.StatusOK, (&commentNode{comment: comment}).json())
+	}
+}
+
+// NewPublicBlogCommentUpdate 评论作者在允许的时间内修改评论, 返回修改后的评论
+func NewPublicBlogCommentUpdate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
 			return
 		}
-		latest := comments[0]
-		ctx.JSON(http.StatusOK, gin.H{
-			"id":          latest.Id,
-			"user_name":   latest.UserName,
-			"content":     latest.Content,
-			"create_time": latest.CreateTime.Format("2006-01-02 15:04:05"),
-		})
+		cid, err := strconv.Atoi(ctx.Param("cid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("cid", "int", "invalid comment id"))
+			return
+		}
+
+		request := &UpdateCommentRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		loginUid, ok := apiLoginUid(ctx)
+		if !ok {
+			return
+		}
+
+		if err := loadCommentSpamPolicy().checkContent(request.Content); err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+
+		comment, err := database.EditPublicBlogComment(bid, cid, loginUid, request.Content, commentEditWindow())
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("edit public blog comment failed", zap.Int("bid", bid), zap.Int("cid", cid), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("edit comment failed"))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, (&commentNode{comment: comment}).json())
 	}
 }
 
@@ -105,48 +294,36 @@ func NewPublicBlogCommentDelete() gin.HandlerFunc {
 		bidParam := ctx.Param("bid")
 		bid, err := strconv.Atoi(bidParam)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid blog id")
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
 			return
 		}
 
 		cidParam := ctx.Param("cid")
 		cid, err := strconv.Atoi(cidParam)
 		if err != nil {
-			ctx.String(http.StatusBadRequest, "invalid comment id")
+			apperr.Write(ctx, apperr.InvalidField("cid", "int", "invalid comment id"))
 			return
 		}
 
 		loginUidValue, ok := ctx.Get("uid")
 		if !ok {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		loginUid, ok := loginUidValue.(int)
 		if !ok || loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 
 		err = database.DeletePublicBlogComment(bid, cid, loginUid)
 		if err != nil {
-			if errors.Is(err, database.ErrInvalidDeleteComment) {
-				ctx.String(http.StatusBadRequest, "invalid parameter")
-				return
-			}
-			if errors.Is(err, database.ErrPublicBlogNotExist) {
-				ctx.String(http.StatusNotFound, "public blog not exist")
-				return
-			}
-			if errors.Is(err, database.ErrCommentNotExist) {
-				ctx.String(http.StatusNotFound, "comment not exist")
-				return
-			}
-			if errors.Is(err, database.ErrCommentNoPermission) {
-				ctx.String(http.StatusForbidden, "no permission to delete comment")
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
 				return
 			}
 			zap.L().Error("delete public blog comment failed", zap.Int("bid", bid), zap.Int("cid", cid), zap.Int("uid", loginUid), zap.Error(err))
-			ctx.String(http.StatusInternalServerError, "delete comment failed")
+			apperr.Write(ctx, apperr.Internal("delete comment failed"))
 			return
 		}
 
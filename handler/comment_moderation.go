@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CommentModeRequest 修改博客的评论模式
+type CommentModeRequest struct {
+	Mode string `json:"mode" form:"mode" binding:"required,oneof=open approval closed"`
+}
+
+// CommentStatusRequest 审核通过或隐藏评论
+type CommentStatusRequest struct {
+	Status string `json:"status" form:"status" binding:"required,oneof=approved hidden"`
+}
+
+// NewPublicBlogCommentMode 公开博客的评论模式, 页面据此决定是否显示评论框
+func NewPublicBlogCommentMode() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		if !database.IsBlogPublic(bid) {
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"mode": database.GetBlogCommentMode(bid)})
+	}
+}
+
+// NewBlogCommentMode 博客作者查看评论模式, 博客未公开时同样可以查看
+func NewBlogCommentMode() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"mode": database.GetBlogCommentMode(blog.Id)})
+	}
+}
+
+// NewBlogCommentModeSet 博客作者修改评论模式: 开放、需要审核或关闭
+func NewBlogCommentModeSet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		request := &CommentModeRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+
+		if err := database.SetBlogCommentMode(blog.Id, request.Mode); err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("set blog comment mode failed", zap.Int("bid", blog.Id), zap.String("mode", request.Mode), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("set comment mode failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"mode": request.Mode})
+	}
+}
+
+// NewBlogComments 博客作者审核评论用的列表, 按发表时间倒序平铺返回, 可按 status 筛选
+func NewBlogComments() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		page, ok := parsePageQuery(ctx)
+		if !ok {
+			return
+		}
+
+		comments, pageInfo, err := database.GetBlogCommentsByStatus(blog.Id, ctx.Query("status"), page)
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get comments failed"))
+			return
+		}
+		items := make([]gin.H, 0, len(comments))
+		for _, comment := range comments {
+			items = append(items, (&commentNode{comment: comment}).json())
+		}
+		ctx.JSON(http.StatusOK, &ListResponse[gin.H]{Items: items, Page: pageInfo})
+	}
+}
+
+// NewBlogCommentStatus 博客作者审核通过或隐藏评论, 隐藏的评论连同其回复不再公开显示
+func NewBlogCommentStatus() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		cid, err := strconv.Atoi(ctx.Param("cid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("cid", "int", "invalid comment id"))
+			return
+		}
+		request := &CommentStatusRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+
+		if err := database.SetCommentStatus(blog.Id, cid, request.Status); err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("set comment status failed", zap.Int("bid", blog.Id), zap.Int("cid", cid), zap.String("status", request.Status), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("set comment status failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"status": request.Status})
+	}
+}
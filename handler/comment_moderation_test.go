@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPublicBlogCommentModeInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/public/:bid/comments/mode", NewPublicBlogCommentMode())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/blog/public/abc/comments/mode", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestNewBlogCommentsInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/:bid/comments", middleware.Auth(), NewBlogComments())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/blog/abc/comments", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestBlogCommentModerationAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/:bid/comments", middleware.Auth(), NewBlogComments())
+	router.PUT("/blog/:bid/comments/:cid/status", middleware.Auth(), NewBlogCommentStatus())
+	router.PUT("/blog/:bid/comments/mode", middleware.Auth(), NewBlogCommentModeSet())
+
+	for _, item := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/blog/1/comments", ""},
+		{http.MethodPut, "/blog/1/comments/2/status", "status=hidden"},
+		{http.MethodPut, "/blog/1/comments/mode", "mode=closed"},
+	} {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(item.method, item.path, strings.NewReader(item.body))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, http.StatusUnauthorized, writer.Code, item.path)
+		assert.Contains(t, writer.Body.String(), "auth failed", item.path)
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// 评论被拒绝的原因, 作为指标的 reason 标签
+const (
+	spamReasonUserRate  = "user_rate"
+	spamReasonIPRate    = "ip_rate"
+	spamReasonDuplicate = "duplicate"
+	spamReasonLinks     = "links"
+	spamReasonKeyword   = "keyword"
+)
+
+var commentRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "myblog_comment_rejected_total",
+	Help: "Total number of comment attempts rejected by anti-spam checks, by reason.",
+}, []string{"reason"})
+
+// linkPattern 粗略识别评论中的链接
+var linkPattern = regexp.MustCompile(`(?i)https?://|www\.`)
+
+// commentSpamPolicy 发表评论的反垃圾规则, 取自配置 comment.spam, 数量和时长为 0 表示不限制
+type commentSpamPolicy struct {
+	rateWindow      time.Duration
+	userRate        int64
+	ipRate          int64
+	duplicateWindow time.Duration
+	maxLinks        int
+	blockedKeywords []string
+	claimed         bool // checkCreate 已登记本次发表的内容, 发表失败时需要 release
+}
+
+const (
+	defaultCommentRateWindow      = time.Minute
+	defaultCommentUserRate        = 5
+	defaultCommentIPRate          = 20
+	defaultCommentDuplicateWindow = 10 * time.Minute
+	defaultCommentMaxLinks        = 3
+)
+
+func loadCommentSpamPolicy() *commentSpamPolicy {
+	policy := &commentSpamPolicy{
+		rateWindow:      defaultCommentRateWindow,
+		userRate:        defaultCommentUserRate,
+		ipRate:          defaultCommentIPRate,
+		duplicateWindow: defaultCommentDuplicateWindow,
+		maxLinks:        defaultCommentMaxLinks,
+	}
+	if blogConfig.IsSet("comment.spam.rate_window") {
+		policy.rateWindow = blogConfig.GetDuration("comment.spam.rate_window")
+	}
+	if blogConfig.IsSet("comment.spam.user_rate") {
+		policy.userRate = blogConfig.GetInt64("comment.spam.user_rate")
+	}
+	if blogConfig.IsSet("comment.spam.ip_rate") {
+		policy.ipRate = blogConfig.GetInt64("comment.spam.ip_rate")
+	}
+	if blogConfig.IsSet("comment.spam.duplicate_window") {
+		policy.duplicateWindow = blogConfig.GetDuration("comment.spam.duplicate_window")
+	}
+	if blogConfig.IsSet("comment.spam.max_links") {
+		policy.maxLinks = blogConfig.GetInt("comment.spam.max_links")
+	}
+	for _, keyword := range blogConfig.GetStringSlice("comment.spam.blocked_keywords") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); len(keyword) > 0 {
+			policy.blockedKeywords = append(policy.blockedKeywords, keyword)
+		}
+	}
+	return policy
+}
+
+// checkContent 检查链接数和屏蔽词, 发表和修改评论都要检查
+func (policy *commentSpamPolicy) checkContent(content string) error {
+	if policy.maxLinks > 0 && len(linkPattern.FindAllStringIndex(content, -1)) > policy.maxLinks {
+		commentRejected.WithLabelValues(spamReasonLinks).Inc()
+		return apperr.InvalidField("content", "links", "too many links in comment")
+	}
+	lower := strings.ToLower(content)
+	for _, keyword := range policy.blockedKeywords {
+		if strings.Contains(lower, keyword) {
+			commentRejected.WithLabelValues(spamReasonKeyword).Inc()
+			return apperr.InvalidField("content", "keyword", "comment contains blocked content")
+		}
+	}
+	return nil
+}
+
+// checkCreate 发表评论前的检查: 先按用户和 IP 限流, 再检查内容, 最后原子地登记内容用于检查重复,
+// 同时提交的相同内容只有一条能通过。通过后评论发表失败时需要调用 release。
+// Redis 不可用时跳过限流和重复检查, 不影响正常发表
+func (policy *commentSpamPolicy) checkCreate(ctx *gin.Context, uid int, content string) error {
+	if policy.rateWindow > 0 {
+		now := time.Now()
+		limits := []struct {
+			scope  string
+			id     string
+			limit  int64
+			reason string
+		}{
+			{"user", strconv.Itoa(uid), policy.userRate, spamReasonUserRate},
+			{"ip", ctx.ClientIP(), policy.ipRate, spamReasonIPRate},
+		}
+		for _, item := range limits {
+			if item.limit <= 0 {
+				continue
+			}
+			count, remaining, err := database.IncrCommentRate(item.scope, item.id, policy.rateWindow, now)
+			if err != nil {
+				zap.L().Error("incr comment rate failed", zap.String("scope", item.scope), zap.Error(err))
+				continue
+			}
+			if count > item.limit {
+				commentRejected.WithLabelValues(item.reason).Inc()
+				retryAfter := int((remaining + time.Second - 1) / time.Second)
+				ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+				return apperr.ErrTooManyRequests.WithMessage("too many comments, try again later").
+					WithDetail(gin.H{"retry_after": retryAfter})
+			}
+		}
+	}
+
+	if err := policy.checkContent(content); err != nil {
+		return err
+	}
+
+	if policy.duplicateWindow > 0 {
+		claimed, err := database.ClaimComment(uid, content, policy.duplicateWindow)
+		if err != nil {
+			zap.L().Error("claim comment failed", zap.Int("uid", uid), zap.Error(err))
+		} else if !claimed {
+			commentRejected.WithLabelValues(spamReasonDuplicate).Inc()
+			return apperr.InvalidField("content", "duplicate", "duplicate comment")
+		}
+		policy.claimed = claimed
+	}
+	return nil
+}
+
+// release 评论发表失败时撤销 checkCreate 的登记, 否则用户重试会被误判为重复
+func (policy *commentSpamPolicy) release(uid int, content string) {
+	if !policy.claimed {
+		return
+	}
+	policy.claimed = false
+	if err := database.ReleaseComment(uid, content); err != nil {
+		zap.L().Error("release comment failed", zap.Int("uid", uid), zap.Error(err))
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"myblog/handler/apperr"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getCommentRejectedValue(t *testing.T, reason string) float64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, commentRejected.WithLabelValues(reason).Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestCommentSpamPolicyCheckContent(t *testing.T) {
+	policy := &commentSpamPolicy{maxLinks: 2, blockedKeywords: []string{"casino"}}
+
+	assert.NoError(t, policy.checkContent("see https://a.example and www.b.example"))
+
+	before := getCommentRejectedValue(t, spamReasonLinks)
+	err := policy.checkContent("http://a.example HTTPS://b.example www.c.example")
+	var appErr *apperr.Error
+	if assert.ErrorAs(t, err, &appErr) {
+		assert.Equal(t, http.StatusBadRequest, appErr.Status)
+		assert.Equal(t, "links", appErr.Fields[0].Rule)
+	}
+	assert.Equal(t, before+1, getCommentRejectedValue(t, spamReasonLinks))
+
+	before = getCommentRejectedValue(t, spamReasonKeyword)
+	err = policy.checkContent("Best CASINO online")
+	if assert.ErrorAs(t, err, &appErr) {
+		assert.Equal(t, "keyword", appErr.Fields[0].Rule)
+	}
+	assert.Equal(t, before+1, getCommentRejectedValue(t, spamReasonKeyword))
+
+	// 链接数为 0 表示不限制
+	policy.maxLinks = 0
+	assert.NoError(t, policy.checkContent("http://a http://b http://c http://d"))
+}
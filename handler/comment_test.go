@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"myblog/database"
 	"myblog/handler/middleware"
 	"myblog/util"
 
@@ -44,6 +45,19 @@ func TestNewPublicBlogCommentsInvalidBid(t *testing.T) {
 	assert.Contains(t, writer.Body.String(), "invalid blog id")
 }
 
+func TestNewPublicBlogCommentsInvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/public/:bid/comments", NewPublicBlogComments())
+
+	for query, field := range map[string]string{"sort=hottest": "sort", "limit=0": "limit"} {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/blog/public/1/comments?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, writer.Code, query)
+		assert.Contains(t, writer.Body.String(), field, query)
+	}
+}
+
 func TestNewPublicBlogCommentCreateInvalidBid(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -69,7 +83,7 @@ func TestNewPublicBlogCommentCreateAuthFailed(t *testing.T) {
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	router.ServeHTTP(writer, request)
 
-	assert.Equal(t, http.StatusForbidden, writer.Code)
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
 	assert.Contains(t, writer.Body.String(), "auth failed")
 }
 
@@ -88,6 +102,21 @@ func TestNewPublicBlogCommentCreateInvalidParameter(t *testing.T) {
 	assert.Contains(t, writer.Body.String(), "invalid parameter")
 }
 
+func TestNewPublicBlogCommentCreateInvalidParent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/public/:bid/comments", middleware.Auth(), NewPublicBlogCommentCreate())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/public/1/comments", strings.NewReader("content=hello&parent_id=-1"))
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "parent_id")
+}
+
 func TestNewPublicBlogCommentDeleteInvalidBid(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -125,6 +154,130 @@ func TestNewPublicBlogCommentDeleteAuthFailed(t *testing.T) {
 	request := httptest.NewRequest(http.MethodDelete, "/blog/public/1/comments/1", nil)
 	router.ServeHTTP(writer, request)
 
-	assert.Equal(t, http.StatusForbidden, writer.Code)
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
 	assert.Contains(t, writer.Body.String(), "auth failed")
 }
+
+func TestNewPublicBlogCommentUpdateInvalidCommentID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/blog/public/:bid/comments/:cid", middleware.Auth(), NewPublicBlogCommentUpdate())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/blog/public/1/comments/not-number", strings.NewReader("content=hello"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid comment id")
+}
+
+func TestNewPublicBlogCommentUpdateInvalidParameter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/blog/public/:bid/comments/:cid", middleware.Auth(), NewPublicBlogCommentUpdate())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/blog/public/1/comments/1", strings.NewReader("content="))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "content")
+}
+
+func TestNewPublicBlogCommentUpdateAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/blog/public/:bid/comments/:cid", middleware.Auth(), NewPublicBlogCommentUpdate())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/blog/public/1/comments/1", strings.NewReader("content=hello"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+}
+
+func newTestComment(id, parentId int, userName string, deleted bool) *database.PublicBlogCommentItem {
+	return &database.PublicBlogCommentItem{
+		Id:         id,
+		ParentId:   parentId,
+		UserName:   userName,
+		Content:    userName + " says",
+		Deleted:    deleted,
+		CreateTime: time.Unix(int64(id), 0),
+	}
+}
+
+func commentTreeIds(nodes []*commentNode) []any {
+	result := make([]any, 0, len(nodes))
+	for _, node := range nodes {
+		if len(node.replies) == 0 {
+			result = append(result, node.comment.Id)
+			continue
+		}
+		result = append(result, map[int][]any{node.comment.Id: commentTreeIds(node.replies)})
+	}
+	return result
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	// 与 GetPublicBlogComments 的顺序一致: 先是顶层评论, 然后逐层是回复
+	comments := []*database.PublicBlogCommentItem{
+		newTestComment(3, 0, "bob", false),
+		newTestComment(1, 0, "alice", true),
+		newTestComment(2, 1, "bob", false),
+		newTestComment(4, 1, "carol", false),
+		newTestComment(5, 4, "dave", false),
+		newTestComment(6, 5, "eve", false),
+		newTestComment(7, 99, "orphan", false),
+	}
+
+	tree := buildCommentTree(comments, 5)
+	assert.Equal(t, []any{
+		3,
+		map[int][]any{1: {2, map[int][]any{4: {map[int][]any{5: {6}}}}}},
+		7,
+	}, commentTreeIds(tree))
+	// 被回复的评论已删除时不显示回复对象
+	assert.Empty(t, tree[1].replies[0].replyTo)
+
+	// 超过最大层数的回复与被回复的评论同级
+	tree = buildCommentTree(comments, 2)
+	assert.Equal(t, []any{
+		3,
+		map[int][]any{1: {2, 4, 5, 6}},
+		7,
+	}, commentTreeIds(tree))
+	assert.Equal(t, "dave", tree[1].replies[3].replyTo)
+
+	tree = buildCommentTree(comments, 1)
+	assert.Equal(t, []any{3, 1, 2, 4, 5, 6, 7}, commentTreeIds(tree))
+}
+
+func TestCommentNodeJSON(t *testing.T) {
+	tree := buildCommentTree([]*database.PublicBlogCommentItem{
+		newTestComment(2, 1, "bob", false),
+		newTestComment(1, 0, "alice", true),
+	}, 5)
+	result := tree[0].json()
+	assert.Equal(t, true, result["deleted"])
+	assert.Equal(t, "", result["user_name"])
+	assert.Nil(t, result["edited"])
+	replies := result["replies"].([]gin.H)
+	if assert.Len(t, replies, 1) {
+		assert.Equal(t, 1, replies[0]["parent_id"])
+		assert.Equal(t, "bob says", replies[0]["content"])
+		assert.Empty(t, replies[0]["replies"])
+		assert.Nil(t, replies[0]["edited"])
+	}
+
+	// 修改过的评论带有修改时间
+	comment := newTestComment(3, 0, "carol", false)
+	editTime := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	comment.EditTime = &editTime
+	assert.Equal(t, "2024-05-01 08:30:00", (&commentNode{comment: comment}).json()["edited"])
+}
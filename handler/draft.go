@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const maxDraftLength = 1 << 20 // 草稿正文上限 1MB
+
+type DraftRequest struct {
+	Title   string `json:"title" form:"title"`
+	Article string `json:"article" form:"article"`
+}
+
+func NewBlogDraftSave() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		request := &DraftRequest{}
+		if err := ctx.ShouldBind(request); err != nil {
+			apperr.Write(ctx, apperr.Bind(err))
+			return
+		}
+		if len(request.Title)+len(request.Article) > maxDraftLength {
+			apperr.Write(ctx, apperr.ErrTooLarge.WithMessage("draft too large"))
+			return
+		}
+
+		draft := &database.BlogDraft{
+			Title:   request.Title,
+			Article: request.Article,
+		}
+		if err := database.SaveDraft(blog.UserId, blog.Id, draft); err != nil {
+			zap.L().Error("save draft failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("save draft failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"save_time": draft.SaveTime.Format("2006-01-02 15:04:05"),
+		})
+	}
+}
+
+func NewBlogDraft() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		draft := database.GetDraft(blog.UserId, blog.Id)
+		if draft == nil {
+			apperr.Write(ctx, apperr.ErrDraftNotExist)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"title":       draft.Title,
+			"article":     draft.Article,
+			"save_time":   draft.SaveTime.Format("2006-01-02 15:04:05"),
+			"update_time": blog.UpdateTime.Format("2006-01-02 15:04:05"),
+			// 草稿早于博客最近一次保存, 说明博客已在别处被修改, 直接恢复草稿会覆盖那次修改
+			"conflict": draft.SaveTime.Before(blog.UpdateTime),
+		})
+	}
+}
+
+func NewBlogDraftDelete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+		database.DeleteDraft(blog.UserId, blog.Id)
+		ctx.String(http.StatusOK, "delete draft success")
+	}
+}
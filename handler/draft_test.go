@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlogDraftSaveInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/:bid/draft", middleware.Auth(), NewBlogDraftSave())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/not-number/draft", strings.NewReader("title=a&article=b"))
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestNewBlogDraftAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/:bid/draft", middleware.Auth(), NewBlogDraft())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/blog/1/draft", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	assert.Contains(t, writer.Body.String(), "auth failed")
+}
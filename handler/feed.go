@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/util"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	feedModeFull    = "full"
+	feedModeSummary = "summary"
+	feedGenerator   = "myblog"
+)
+
+var blogConfig = util.CreateConfig("blog")
+
+// feedChannel 两种订阅格式共用的数据, 由公开博客列表构造
+type feedChannel struct {
+	Title    string
+	Link     string // 对应的网页地址
+	Self     string // 订阅源自身的地址
+	Updated  time.Time
+	FullMode bool
+	Blogs    []*database.PublicBlogDetail
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Guid        rssGuid    `xml:"guid"`
+	PubDate     string     `xml:"pubDate"`
+	Categories  []string   `xml:"category"`
+	Description string     `xml:"description"`
+	Content     *feedCDATA `xml:"content:encoded,omitempty"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type feedCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// NewPublicFeed 全站公开博客的订阅源, format 为 rss 或 atom
+func NewPublicFeed(format string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		channel, ok := loadFeedChannel(ctx, 0)
+		if !ok {
+			return
+		}
+		channel.Title = "MyBlog 公共博客"
+		channel.Link = siteBaseURL(ctx) + "/blog/public"
+		writeFeed(ctx, format, channel)
+	}
+}
+
+// NewUserFeed 某个作者公开博客的 RSS 订阅源
+func NewUserFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uid, err := strconv.Atoi(ctx.Param("uid"))
+		if err != nil || uid <= 0 {
+			apperr.Write(ctx, apperr.InvalidField("uid", "int", "invalid user id"))
+			return
+		}
+		user := database.GetUserById(uid)
+		if user == nil {
+			apperr.Write(ctx, apperr.ErrUserNotExist)
+			return
+		}
+
+		channel, ok := loadFeedChannel(ctx, uid)
+		if !ok {
+			return
+		}
+		channel.Title = user.Name + " 的博客"
+		channel.Link = siteBaseURL(ctx) + "/blog/public"
+		writeFeed(ctx, "rss", channel)
+	}
+}
+
+// loadFeedChannel 读取最新发布的公开博客, uid 为 0 表示全部作者; 出错时已写回响应并返回 false
+func loadFeedChannel(ctx *gin.Context, uid int) (*feedChannel, bool) {
+	fullMode := blogConfig.GetBool("feed.full_content")
+	switch ctx.Query("mode") {
+	case "":
+	case feedModeFull:
+		fullMode = true
+	case feedModeSummary:
+		fullMode = false
+	default:
+		apperr.Write(ctx, apperr.InvalidField("mode", "oneof", "invalid mode"))
+		return nil, false
+	}
+
+	query := &database.PublicBlogQuery{
+		PageQuery: database.PageQuery{Limit: blogConfig.GetInt("feed.size")},
+		UserId:    uid,
+	}
+	previews, _, err := database.GetPublicBlogList(query)
+	if err != nil {
+		zap.L().Error("load feed failed", zap.Int("uid", uid), zap.Error(err))
+		apperr.Write(ctx, apperr.Internal("load feed failed"))
+		return nil, false
+	}
+	bids := make([]int, 0, len(previews))
+	for _, preview := range previews {
+		bids = append(bids, preview.Id)
+	}
+
+	channel := &feedChannel{
+		Self:     siteBaseURL(ctx) + ctx.Request.URL.RequestURI(),
+		FullMode: fullMode,
+		Blogs:    database.GetPublicBlogsByIds(bids),
+	}
+	for _, blog := range channel.Blogs {
+		if blog.UpdateTime.After(channel.Updated) {
+			channel.Updated = blog.UpdateTime
+		}
+		if blog.PublishTime.After(channel.Updated) {
+			channel.Updated = blog.PublishTime
+		}
+	}
+	return channel, true
+}
+
+// feedETag 由条目的 id、更新时间、输出模式和订阅源地址计算, 任何一篇博客变化都会改变 ETag;
+// 条目链接可能取自请求的 Host, 订阅源地址与之同源
+func feedETag(format string, channel *feedChannel) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s|%s|%s|%t", format, channel.Title, channel.Self, channel.FullMode)
+	for _, blog := range channel.Blogs {
+		fmt.Fprintf(hash, "|%d.%d.%d", blog.Id, blog.UpdateTime.UnixNano(), blog.PublishTime.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+func writeFeed(ctx *gin.Context, format string, channel *feedChannel) {
+	etag := feedETag(format, channel)
+	ctx.Header("ETag", etag)
+	if !channel.Updated.IsZero() {
+		ctx.Header("Last-Modified", channel.Updated.UTC().Format(http.TimeFormat))
+	}
+	if notModified(ctx, etag, channel.Updated) {
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	var feed any
+	contentType := "application/rss+xml; charset=utf-8"
+	if format == "atom" {
+		feed = buildAtomFeed(ctx, channel)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		feed = buildRSSFeed(ctx, channel)
+	}
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		zap.L().Error("marshal feed failed", zap.String("format", format), zap.Error(err))
+		apperr.Write(ctx, apperr.Internal("load feed failed"))
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, append([]byte(xml.Header), body...))
+}
+
+func buildRSSFeed(ctx *gin.Context, channel *feedChannel) *rssFeed {
+	base := siteBaseURL(ctx)
+	feed := &rssFeed{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		Channel: rssChannel{
+			Title:       channel.Title,
+			Link:        channel.Link,
+			Description: channel.Title,
+			AtomLink:    atomLink{Href: channel.Self, Rel: "self", Type: "application/rss+xml"},
+			Generator:   feedGenerator,
+		},
+	}
+	if !channel.Updated.IsZero() {
+		feed.Channel.LastBuildDate = channel.Updated.Format(time.RFC1123Z)
+	}
+	for _, blog := range channel.Blogs {
+		link := base + publicBlogPath(blog.Id, blog.Slug)
+		item := rssItem{
+			Title:       blog.Title,
+			Link:        link,
+			Guid:        rssGuid{IsPermaLink: true, Value: link},
+			PubDate:     blog.PublishTime.Format(time.RFC1123Z),
+			Categories:  feedCategories(blog),
+			Description: feedSummary(blog.Article),
+		}
+		if channel.FullMode {
+			item.Content = &feedCDATA{Value: string(util.RenderMarkdownCached(blog.Id, blog.UpdateTime, blog.Article))}
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return feed
+}
+
+func buildAtomFeed(ctx *gin.Context, channel *feedChannel) *atomFeed {
+	base := siteBaseURL(ctx)
+	updated := channel.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0) // Atom 要求 updated 必填, 没有博客时给一个固定值
+	}
+	feed := &atomFeed{
+		Id:      strings.SplitN(channel.Self, "?", 2)[0], // id 不随 mode 等查询参数变化
+		Title:   channel.Title,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: channel.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: channel.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Generator: feedGenerator,
+	}
+	for _, blog := range channel.Blogs {
+		link := base + publicBlogPath(blog.Id, blog.Slug)
+		entry := atomEntry{
+			Id:        link,
+			Title:     blog.Title,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: blog.PublishTime.Format(time.RFC3339),
+			Updated:   blog.UpdateTime.Format(time.RFC3339),
+			Author:    atomAuthor{Name: blog.UserName},
+			Summary:   atomText{Type: "text", Value: feedSummary(blog.Article)},
+		}
+		for _, term := range feedCategories(blog) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: term})
+		}
+		if channel.FullMode {
+			entry.Content = &atomText{Type: "html", Value: string(util.RenderMarkdownCached(blog.Id, blog.UpdateTime, blog.Article))}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+func feedCategories(blog *database.PublicBlogDetail) []string {
+	categories := make([]string, 0, len(blog.Tags)+1)
+	if len(blog.Category) > 0 {
+		categories = append(categories, blog.Category)
+	}
+	return append(categories, blog.Tags...)
+}
+
+// feedSummary 取正文纯文本的前 feed.summary_length 个字符作为摘要
+func feedSummary(article string) string {
+	text := util.MarkdownToText(article)
+	length := blogConfig.GetInt("feed.summary_length")
+	if runes := []rune(text); length > 0 && len(runes) > length {
+		return string(runes[:length]) + "…"
+	}
+	return text
+}
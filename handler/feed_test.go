@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myblog/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFeedTestChannel(fullMode bool) *feedChannel {
+	publish := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	update := publish.Add(2 * time.Hour)
+	return &feedChannel{
+		Title:    "测试订阅",
+		Link:     "http://example.com/blog/public",
+		Self:     "http://example.com/feed.xml",
+		Updated:  update,
+		FullMode: fullMode,
+		Blogs: []*database.PublicBlogDetail{{
+			Id:          7,
+			UserName:    "alice",
+			Title:       "Go <泛型>",
+			Article:     "# 标题\n\n正文 **加粗**",
+			Category:    "技术",
+			Tags:        []string{"go"},
+			UpdateTime:  update,
+			PublishTime: publish,
+		}},
+	}
+}
+
+func newFeedTestContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	writer := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(writer)
+	ctx.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return ctx, writer
+}
+
+func TestWriteFeedRSS(t *testing.T) {
+	ctx, writer := newFeedTestContext("http://example.com/feed.xml")
+	writeFeed(ctx, "rss", newFeedTestChannel(false))
+
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Header().Get("Content-Type"), "application/rss+xml")
+	assert.Equal(t, "Sat, 01 Mar 2025 10:00:00 GMT", writer.Header().Get("Last-Modified"))
+
+	var feed struct {
+		Channel struct {
+			Items []struct {
+				Title       string `xml:"title"`
+				Link        string `xml:"link"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+				Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	assert.NoError(t, xml.Unmarshal(writer.Body.Bytes(), &feed))
+	if assert.Len(t, feed.Channel.Items, 1) {
+		item := feed.Channel.Items[0]
+		assert.Equal(t, "Go <泛型>", item.Title)
+		assert.Equal(t, "http://example.com/blog/public/7", item.Link)
+		assert.Equal(t, "Sat, 01 Mar 2025 08:00:00 +0000", item.PubDate)
+		assert.Equal(t, "标题 正文 加粗", item.Description)
+		assert.Empty(t, item.Content, "summary mode should not include full content")
+	}
+}
+
+func TestWriteFeedAtomFullContent(t *testing.T) {
+	ctx, writer := newFeedTestContext("http://example.com/feed.atom")
+	writeFeed(ctx, "atom", newFeedTestChannel(true))
+
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Header().Get("Content-Type"), "application/atom+xml")
+
+	var feed struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Content   string `xml:"content"`
+		} `xml:"entry"`
+	}
+	assert.NoError(t, xml.Unmarshal(writer.Body.Bytes(), &feed))
+	assert.Equal(t, "2025-03-01T10:00:00Z", feed.Updated)
+	if assert.Len(t, feed.Entries, 1) {
+		entry := feed.Entries[0]
+		assert.Equal(t, "2025-03-01T08:00:00Z", entry.Published)
+		assert.Equal(t, "2025-03-01T10:00:00Z", entry.Updated)
+		assert.Contains(t, entry.Content, "<strong>加粗</strong>")
+	}
+}
+
+func TestWriteFeedConditionalGet(t *testing.T) {
+	channel := newFeedTestChannel(false)
+	ctx, writer := newFeedTestContext("http://example.com/feed.xml")
+	writeFeed(ctx, "rss", channel)
+	etag := writer.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	ctx, writer = newFeedTestContext("http://example.com/feed.xml")
+	ctx.Request.Header.Set("If-None-Match", etag)
+	writeFeed(ctx, "rss", channel)
+	assert.Equal(t, http.StatusNotModified, writer.Code)
+	assert.Empty(t, writer.Body.String())
+
+	ctx, writer = newFeedTestContext("http://example.com/feed.xml")
+	ctx.Request.Header.Set("If-Modified-Since", "Sat, 01 Mar 2025 10:00:00 GMT")
+	writeFeed(ctx, "rss", channel)
+	assert.Equal(t, http.StatusNotModified, writer.Code)
+
+	// 模式不同输出不同, ETag 也必须不同
+	channel.FullMode = true
+	ctx, writer = newFeedTestContext("http://example.com/feed.xml")
+	ctx.Request.Header.Set("If-None-Match", etag)
+	writeFeed(ctx, "rss", channel)
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.True(t, strings.Contains(writer.Body.String(), "<content:encoded>"))
+
+	// 链接取自请求的 Host 时, 不同 Host 的 ETag 不同
+	other := newFeedTestChannel(false)
+	other.Self = "http://evil.example/feed.xml"
+	assert.NotEqual(t, feedETag("rss", newFeedTestChannel(false)), feedETag("rss", other))
+}
+
+func TestNewPublicFeedInvalidMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/feed.xml", NewPublicFeed("rss"))
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/feed.xml?mode=everything", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid mode")
+}
+
+func TestNewUserFeedInvalidUid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/user/:uid/feed.xml", NewUserFeed())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/user/abc/feed.xml", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid user id")
+}
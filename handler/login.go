@@ -2,6 +2,7 @@ package handler
 
 import (
 	"myblog/database"
+	"myblog/handler/apperr"
 	"myblog/util"
 	"net/http"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"go.uber.org/zap"
 )
 
+// LoginResponse 登录成功的响应, 失败时返回 apperr.ErrorResponse
 type LoginResponse struct {
 	Code  int    `json:"code"`
 	Msg   string `json:"msg"`
@@ -27,86 +29,27 @@ func NewLogin() gin.HandlerFunc {
 		name := ctx.PostForm("user")
 		pass := ctx.PostForm("pass")
 		if len(name) == 0 {
-			ctx.JSON(
-				http.StatusBadRequest,
-				&LoginResponse{
-					Code:  1,
-					Msg:   "must indicate user name",
-					Uid:   0,
-					Token: "",
-				},
-			)
+			apperr.Write(ctx, apperr.InvalidField("user", "required", "must indicate user name"))
 			return
 		}
 		if len(pass) != 32 {
-			ctx.JSON(
-				http.StatusBadRequest,
-				&LoginResponse{
-					Code:  2,
-					Msg:   "invalid password",
-					Uid:   0,
-					Token: "",
-				},
-			)
+			apperr.Write(ctx, apperr.InvalidField("pass", "len", "invalid password"))
 			return
 		}
 		user := database.GetUserByName(name)
-		if user == nil {
-			ctx.JSON(
-				http.StatusForbidden,
-				&LoginResponse{
-					Code:  3,
-					Msg:   "user not exist",
-					Uid:   0,
-					Token: "",
-				},
-			)
-			return
-		}
-		if user.PassWd != pass {
-			ctx.JSON(
-				http.StatusForbidden,
-				&LoginResponse{
-					Code:  4,
-					Msg:   "incorrect password",
-					Uid:   0,
-					Token: "",
-				})
+		if user == nil || user.PassWd != pass {
+			// 不区分用户不存在和密码错误, 避免被用来探测用户名
+			apperr.Write(ctx, apperr.ErrLoginFailed)
 			return
 		}
 		zap.L().Info("user login success", zap.String("name", name), zap.Int("uid", user.Id))
 
-		header := &util.JwtHeader{}
-		payload := &util.JwtPayload{
-			Issue:       "blog",
-			IssueAt:     time.Now().Unix(),
-			Expiration:  time.Now().Add(database.TOKEN_EXPIRE).Add(24 * time.Hour).Unix(),
-			UserDefined: map[string]any{"uid": user.Id},
-		}
-		jwtToken, err := util.GenJwt(header, payload, util.JWT_SECRET)
+		jwtToken, _, err := issueLoginToken(ctx, user)
 		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				&LoginResponse{
-					Code:  5,
-					Msg:   "generate jwtToken failed",
-					Uid:   0,
-					Token: "",
-				},
-			)
+			zap.L().Error("issue login token failed", zap.Int("uid", user.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("generate jwtToken failed"))
 			return
 		}
-		refreshToken := util.RandStringRunes(20)
-		database.SetToken(refreshToken, jwtToken)
-		ctx.SetCookie(
-			"refresh_token",
-			refreshToken,
-			int(database.TOKEN_EXPIRE.Seconds()),
-			"/",
-			"",
-			false,
-			true,
-		)
 		ctx.JSON(
 			http.StatusOK,
 			&LoginResponse{
@@ -116,8 +59,34 @@ func NewLogin() gin.HandlerFunc {
 				Token: jwtToken,
 			},
 		)
+	}
+}
 
+// issueLoginToken 为登录成功的用户签发 auth token 和 refresh token, refresh token 同时写入 cookie
+func issueLoginToken(ctx *gin.Context, user *database.User) (string, string, error) {
+	header := &util.JwtHeader{}
+	payload := &util.JwtPayload{
+		Issue:       "blog",
+		IssueAt:     time.Now().Unix(),
+		Expiration:  time.Now().Add(database.TOKEN_EXPIRE).Add(24 * time.Hour).Unix(),
+		UserDefined: map[string]any{"uid": user.Id},
+	}
+	jwtToken, err := util.GenJwt(header, payload, util.JWT_SECRET)
+	if err != nil {
+		return "", "", err
 	}
+	refreshToken := util.RandStringRunes(20)
+	database.SetToken(refreshToken, jwtToken)
+	ctx.SetCookie(
+		"refresh_token",
+		refreshToken,
+		int(database.TOKEN_EXPIRE.Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+	return jwtToken, refreshToken, nil
 }
 
 func GetAuthToken(ctx *gin.Context) {
@@ -132,47 +101,24 @@ func NewRegister() gin.HandlerFunc {
 		pass := ctx.PostForm("pass")
 
 		if len(name) == 0 {
-			ctx.JSON(
-				http.StatusBadRequest,
-				&RegisterResponse{
-					Code: 1,
-					Msg:  "must indicate user name",
-				},
-			)
+			apperr.Write(ctx, apperr.InvalidField("user", "required", "must indicate user name"))
 			return
 		}
 
 		if len(pass) != 32 {
-			ctx.JSON(
-				http.StatusBadRequest,
-				&RegisterResponse{
-					Code: 2,
-					Msg:  "invalid password",
-				},
-			)
+			apperr.Write(ctx, apperr.InvalidField("pass", "len", "invalid password"))
 			return
 		}
 
 		if database.GetUserByName(name) != nil {
-			ctx.JSON(
-				http.StatusConflict,
-				&RegisterResponse{
-					Code: 3,
-					Msg:  "user already exist",
-				},
-			)
+			apperr.Write(ctx, apperr.ErrUserExist)
 			return
 		}
 
 		err := database.CreateUser(name, pass)
 		if err != nil {
-			ctx.JSON(
-				http.StatusInternalServerError,
-				&RegisterResponse{
-					Code: 4,
-					Msg:  "create user failed",
-				},
-			)
+			zap.L().Error("create user failed", zap.String("name", name), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("create user failed"))
 			return
 		}
 
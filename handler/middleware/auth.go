@@ -1,8 +1,8 @@
 package middleware
 
 import (
+	"myblog/handler/apperr"
 	"myblog/util"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,8 +34,7 @@ func Auth() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		loginUid := GetLoginUid(ctx)
 		if loginUid <= 0 {
-			ctx.String(http.StatusForbidden, "auth failed")
-			ctx.Abort()
+			apperr.Write(ctx, apperr.ErrAuthFailed)
 			return
 		}
 		ctx.Set("uid", loginUid)
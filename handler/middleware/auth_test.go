@@ -84,7 +84,7 @@ func TestGetLoginUid(t *testing.T) {
 func TestAuth(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	t.Run("invalid token aborts with 403", func(t *testing.T) {
+	t.Run("invalid token aborts with 401", func(t *testing.T) {
 		router := gin.New()
 		handlerExecuted := false
 		router.GET("/protected", Auth(), func(ctx *gin.Context) {
@@ -98,8 +98,8 @@ func TestAuth(t *testing.T) {
 
 		router.ServeHTTP(writer, request)
 
-		assert.Equal(t, http.StatusForbidden, writer.Code)
-		assert.Contains(t, writer.Body.String(), "auth failed")
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		assert.JSONEq(t, `{"error":{"code":"auth_failed","message":"auth failed"}}`, writer.Body.String())
 		assert.False(t, handlerExecuted)
 	})
 
@@ -0,0 +1,576 @@
+package handler
+
+import (
+	"mime/multipart"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	openapiVersion      = "3.0.3"
+	openapiSecurityName = "authToken"
+)
+
+// openapiOperation 一个路由的文档信息。路径和方法以 gin 实际注册的路由为准,
+// 这里只补充摘要、参数和请求/响应结构; 没有登记的路由不会出现在文档中
+type openapiOperation struct {
+	Summary  string
+	Tag      string
+	Auth     bool                // 需要请求头 auth_token
+	Query    []*openapiParameter // 查询参数和请求头, 对应 handler 中的 ctx.Query / ctx.GetHeader
+	Body     any                 // 请求体绑定结构, 按 json tag 与 binding 约束生成 schema
+	Consumes string              // 请求体类型, 默认同时接受 JSON 和表单
+	Status   int                 // 成功时的状态码, 默认 200
+	Response any                 // JSON 响应结构, 为 nil 时只给出内容类型
+	Produces string              // 响应类型, 默认 application/json
+	Errors   []int               // 400/401 之外需要单独列出的错误状态码
+}
+
+// UploadForm 上传接口的表单, handler 中通过 ctx.FormFile 读取
+type UploadForm struct {
+	File *multipart.FileHeader `json:"file" form:"file" binding:"required"`
+}
+
+// openapiPathParams 路径参数的 schema, 未登记的按字符串处理
+var openapiPathParams = map[string]*openapiParameter{
+	"uid":  {Description: "用户 id", Schema: &openapiSchema{Type: "integer", Minimum: ptr(0.0), ExclusiveMinimum: true}},
+	"bid":  {Description: "博客 id", Schema: &openapiSchema{Type: "integer", Minimum: ptr(0.0), ExclusiveMinimum: true}},
+	"cid":  {Description: "评论 id", Schema: &openapiSchema{Type: "integer", Minimum: ptr(0.0), ExclusiveMinimum: true}},
+	"rid":  {Description: "历史版本 id", Schema: &openapiSchema{Type: "integer", Minimum: ptr(0.0), ExclusiveMinimum: true}},
+	"slug": {Description: "博客别名", Schema: &openapiSchema{Type: "string"}},
+	"tag":  {Description: "标签", Schema: &openapiSchema{Type: "string"}},
+	"name": {Description: "文件名, 格式为 <sha256>.<扩展名>", Schema: &openapiSchema{Type: "string"}},
+}
+
+// openapiOperations 以 "方法 路径" 为键登记每个路由, 新增路由时需要同步登记
+var openapiOperations = map[string]*openapiOperation{
+	"GET /metrics":          {Summary: "Prometheus 指标", Tag: "system", Produces: "text/plain"},
+	"GET /openapi.json":     {Summary: "OpenAPI 文档", Tag: "system"},
+	"GET /openapi":          {Summary: "OpenAPI 文档查看页", Tag: "system", Produces: "text/html"},
+	"GET /":                 {Summary: "首页", Tag: "page", Produces: "text/html"},
+	"GET /login":            {Summary: "登录页", Tag: "page", Produces: "text/html"},
+	"POST /login/submit":    {Summary: "登录, refresh token 写入 cookie", Tag: "auth", Body: ApiLoginRequest{}, Consumes: "application/x-www-form-urlencoded", Response: LoginResponse{}},
+	"POST /register/submit": {Summary: "注册", Tag: "auth", Body: ApiLoginRequest{}, Consumes: "application/x-www-form-urlencoded", Response: RegisterResponse{}},
+	"POST /token":           {Summary: "用 refresh token 换取 auth token", Tag: "auth", Body: ApiTokenRequest{}, Consumes: "application/x-www-form-urlencoded", Produces: "text/plain"},
+
+	"GET /p/:slug":            {Summary: "按别名访问公开博客, 旧别名 301 到当前别名", Tag: "site", Produces: "text/html"},
+	"GET /sitemap.xml":        {Summary: "sitemap", Tag: "site", Produces: "application/xml"},
+	"GET /robots.txt":         {Summary: "robots.txt", Tag: "site", Produces: "text/plain"},
+	"GET /feed.xml":           {Summary: "全站 RSS 订阅源", Tag: "feed", Query: []*openapiParameter{feedModeParam()}, Produces: "application/rss+xml"},
+	"GET /feed.atom":          {Summary: "全站 Atom 订阅源", Tag: "feed", Query: []*openapiParameter{feedModeParam()}, Produces: "application/atom+xml"},
+	"GET /user/:uid/feed.xml": {Summary: "作者的 RSS 订阅源", Tag: "feed", Query: []*openapiParameter{feedModeParam()}, Produces: "application/rss+xml"},
+
+	"POST /upload":      {Summary: "上传图片或附件", Tag: "upload", Auth: true, Body: UploadForm{}, Consumes: "multipart/form-data"},
+	"GET /upload/:name": {Summary: "下载上传的文件", Tag: "upload", Produces: "application/octet-stream"},
+	"GET /uploads":      {Summary: "当前用户上传过的文件", Tag: "upload", Auth: true},
+
+	"GET /blog/belong": {Summary: "判断博客是否属于 token 对应的用户", Tag: "blog", Produces: "text/plain", Query: []*openapiParameter{
+		queryParam("bid", "integer", "博客 id", true),
+		queryParam("token", "string", "auth token", false),
+	}},
+	"GET /blog/trash":                          {Summary: "回收站中的博客", Tag: "trash", Auth: true},
+	"GET /blog/public":                         {Summary: "公开博客列表页", Tag: "public", Produces: "text/html", Query: append(pageParams(), publicFilterParams()...)},
+	"GET /blog/list/:uid":                      {Summary: "用户的博客列表页", Tag: "blog", Produces: "text/html", Query: pageParams()},
+	"GET /blog/:bid":                           {Summary: "博客详情页", Tag: "blog", Produces: "text/html"},
+	"GET /blog/public/search":                  {Summary: "搜索公开博客, 按相关度返回最多 50 条, 不分页", Tag: "public", Query: []*openapiParameter{queryParam("q", "string", "关键词", true)}},
+	"GET /blog/public/tag/:tag":                {Summary: "按标签浏览公开博客", Tag: "public", Produces: "text/html", Query: pageParams()},
+	"GET /blog/public/:bid":                    {Summary: "公开博客详情页", Tag: "public", Produces: "text/html"},
+	"GET /blog/public/:bid/views":              {Summary: "公开博客的累计阅读量和访客数", Tag: "public"},
+	"GET /blog/public/:bid/reactions":          {Summary: "公开博客的表态统计, 带 auth_token 时包含自己的表态", Tag: "reaction", Response: database.BlogReactionSummary{}},
+	"PUT /blog/public/:bid/reactions/:kind":    {Summary: "添加表态, 重复添加无影响", Tag: "reaction", Auth: true, Response: database.BlogReactionSummary{}},
+	"DELETE /blog/public/:bid/reactions/:kind": {Summary: "取消表态, 未表态时无影响", Tag: "reaction", Auth: true, Response: database.BlogReactionSummary{}},
+	"GET /blog/public/:bid/bookmark":           {Summary: "是否已收藏该博客", Tag: "bookmark", Auth: true},
+	"PUT /blog/public/:bid/bookmark":           {Summary: "收藏公开博客, 重复收藏无影响", Tag: "bookmark", Auth: true},
+	"DELETE /blog/public/:bid/bookmark":        {Summary: "取消收藏, 博客未公开时也可取消", Tag: "bookmark", Auth: true},
+	"GET /bookmarks":                           {Summary: "我的收藏页面", Tag: "bookmark", Produces: "text/html"},
+	"GET /bookmarks/list":                      {Summary: "我收藏的公开博客, 按收藏时间倒序", Tag: "bookmark", Auth: true, Query: pageParams(), Response: ListResponse[*BookmarkResource]{}},
+	"GET /blog/public/:bid/comments":           {Summary: "公开博客的评论树, 按顶层评论分页", Tag: "comment", Query: commentQueryParams()},
+	"POST /blog/public/:bid/comments":          {Summary: "发表评论, 超过频率限制时返回 429", Tag: "comment", Auth: true, Body: CreateCommentRequest{}, Errors: []int{http.StatusTooManyRequests}},
+	"PUT /blog/public/:bid/comments/:cid":      {Summary: "在允许的时间内修改自己的评论", Tag: "comment", Auth: true, Body: UpdateCommentRequest{}},
+	"DELETE /blog/public/:bid/comments/:cid":   {Summary: "删除评论, 评论作者和博客作者都可以删除", Tag: "comment", Auth: true},
+	"GET /blog/public/:bid/comments/mode":      {Summary: "公开博客的评论模式", Tag: "comment"},
+
+	"GET /blog/:bid/comments":             {Summary: "博客作者审核用的评论列表, 按发表时间倒序", Tag: "moderation", Auth: true, Query: commentStatusParams()},
+	"PUT /blog/:bid/comments/:cid/status": {Summary: "审核通过或隐藏评论", Tag: "moderation", Auth: true, Body: CommentStatusRequest{}},
+	"GET /blog/:bid/comments/mode":        {Summary: "博客的评论模式", Tag: "moderation", Auth: true},
+	"PUT /blog/:bid/comments/mode":        {Summary: "修改评论模式: 开放、需要审核或关闭", Tag: "moderation", Auth: true, Body: CommentModeRequest{}},
+
+	"POST /blog/create":    {Summary: "新建博客", Tag: "blog", Auth: true, Body: CreateRequest{}},
+	"POST /blog/update":    {Summary: "更新博客, 版本不一致时返回 409", Tag: "blog", Auth: true, Query: []*openapiParameter{ifMatchParam()}, Body: UpdateRequest{}, Produces: "text/plain", Errors: []int{http.StatusConflict, http.StatusPreconditionRequired}},
+	"POST /blog/publish":   {Summary: "发布博客, 可定时发布/取消发布", Tag: "blog", Auth: true, Body: PublishRequest{}, Produces: "text/plain"},
+	"POST /blog/unpublish": {Summary: "取消发布博客", Tag: "blog", Auth: true, Body: PublishRequest{}, Produces: "text/plain"},
+
+	"GET /blog/:bid/revisions": {Summary: "博客的历史版本", Tag: "revision", Auth: true},
+	"GET /blog/:bid/revisions/diff": {Summary: "比较两个历史版本, 正文过长时返回 413", Tag: "revision", Auth: true, Query: []*openapiParameter{
+		queryParam("from", "integer", "起始版本 id", true),
+		queryParam("to", "integer", "目标版本 id", true),
+	}, Errors: []int{http.StatusRequestEntityTooLarge}},
+	"POST /blog/:bid/revisions/:rid/restore": {Summary: "恢复到历史版本", Tag: "revision", Auth: true, Produces: "text/plain"},
+
+	"GET /blog/:bid/draft":    {Summary: "读取自动保存的草稿", Tag: "draft", Auth: true},
+	"POST /blog/:bid/draft":   {Summary: "自动保存草稿", Tag: "draft", Auth: true, Body: DraftRequest{}},
+	"DELETE /blog/:bid/draft": {Summary: "丢弃草稿", Tag: "draft", Auth: true, Produces: "text/plain"},
+
+	"DELETE /blog/:bid":       {Summary: "把博客移入回收站", Tag: "trash", Auth: true, Produces: "text/plain"},
+	"POST /blog/:bid/restore": {Summary: "从回收站恢复博客", Tag: "trash", Auth: true, Produces: "text/plain"},
+
+	"POST /api/v1/auth/login":    {Summary: "登录", Tag: "api", Body: ApiLoginRequest{}},
+	"POST /api/v1/auth/register": {Summary: "注册", Tag: "api", Body: ApiLoginRequest{}, Status: http.StatusCreated},
+	"POST /api/v1/auth/token":    {Summary: "用 refresh token 换取 auth token", Tag: "api", Body: ApiTokenRequest{}},
+
+	"GET /api/v1/blogs":                 {Summary: "自己的博客列表", Tag: "api", Auth: true, Query: pageParams(), Response: ListResponse[*BlogResource]{}},
+	"POST /api/v1/blogs":                {Summary: "新建博客", Tag: "api", Auth: true, Body: ApiBlogRequest{}, Status: http.StatusCreated, Response: BlogResource{}},
+	"GET /api/v1/blogs/:bid":            {Summary: "自己的博客详情", Tag: "api", Auth: true, Response: BlogResource{}},
+	"PUT /api/v1/blogs/:bid":            {Summary: "更新博客, 版本不一致时返回 409", Tag: "api", Auth: true, Query: []*openapiParameter{ifMatchParam()}, Body: ApiBlogUpdateRequest{}, Response: BlogResource{}, Errors: []int{http.StatusConflict, http.StatusPreconditionRequired}},
+	"DELETE /api/v1/blogs/:bid":         {Summary: "把博客移入回收站", Tag: "api", Auth: true, Status: http.StatusNoContent},
+	"POST /api/v1/blogs/:bid/publish":   {Summary: "发布博客, 可定时发布/取消发布", Tag: "api", Auth: true, Body: ApiPublishRequest{}},
+	"DELETE /api/v1/blogs/:bid/publish": {Summary: "取消发布博客", Tag: "api", Auth: true, Status: http.StatusNoContent},
+
+	"GET /api/v1/public/blogs":                       {Summary: "公开博客列表", Tag: "api", Query: append(append(pageParams(), publicFilterParams()...), queryParam("user_id", "integer", "作者 id", false)), Response: ListResponse[*PublicBlogResource]{}},
+	"GET /api/v1/public/blogs/:bid":                  {Summary: "公开博客详情", Tag: "api", Response: PublicBlogResource{}},
+	"GET /api/v1/public/blogs/:bid/comments":         {Summary: "公开博客的评论列表, 按顶层评论分页", Tag: "api", Query: commentQueryParams(), Response: ListResponse[*CommentResource]{}},
+	"POST /api/v1/public/blogs/:bid/comments":        {Summary: "发表评论, 超过频率限制时返回 429", Tag: "api", Auth: true, Body: CreateCommentRequest{}, Status: http.StatusCreated, Response: CommentResource{}, Errors: []int{http.StatusTooManyRequests}},
+	"PUT /api/v1/public/blogs/:bid/comments/:cid":    {Summary: "在允许的时间内修改自己的评论", Tag: "api", Auth: true, Body: UpdateCommentRequest{}, Response: CommentResource{}},
+	"DELETE /api/v1/public/blogs/:bid/comments/:cid": {Summary: "删除自己的评论", Tag: "api", Auth: true, Status: http.StatusNoContent},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func queryParam(name, typ, description string, required bool) *openapiParameter {
+	return &openapiParameter{Name: name, In: "query", Description: description, Required: required, Schema: &openapiSchema{Type: typ}}
+}
+
+func pageParams() []*openapiParameter {
+	limit := queryParam("limit", "integer", "每页条数", false)
+	limit.Schema.Minimum = ptr(1.0)
+	limit.Schema.Maximum = ptr(float64(database.MaxPageSize))
+	limit.Schema.Default = database.DefaultPageSize
+	return []*openapiParameter{limit, queryParam("cursor", "string", "上一次响应中的翻页游标", false)}
+}
+
+func publicFilterParams() []*openapiParameter {
+	return []*openapiParameter{
+		queryParam("tag", "string", "按标签筛选", false),
+		queryParam("category", "string", "按分类筛选", false),
+	}
+}
+
+func commentQueryParams() []*openapiParameter {
+	sort := queryParam("sort", "string", "顶层评论的排序, 默认 newest", false)
+	sort.Schema.Enum = []string{database.CommentSortNewest, database.CommentSortOldest}
+	return append(pageParams(), sort)
+}
+
+func commentStatusParams() []*openapiParameter {
+	status := queryParam("status", "string", "按审核状态筛选, 不传返回全部", false)
+	status.Schema.Enum = []string{database.CommentStatusPending, database.CommentStatusHidden, database.CommentStatusApproved}
+	return append(pageParams(), status)
+}
+
+func feedModeParam() *openapiParameter {
+	mode := queryParam("mode", "string", "输出全文或摘要, 默认取配置 feed.full_content", false)
+	mode.Schema.Enum = []string{feedModeFull, feedModeSummary}
+	return mode
+}
+
+func ifMatchParam() *openapiParameter {
+	return &openapiParameter{Name: "If-Match", In: "header", Description: "详情接口返回的 ETag, 与请求体中的 version 二选一", Schema: &openapiSchema{Type: "string"}}
+}
+
+type openapiDocument struct {
+	OpenAPI    string                                        `json:"openapi"`
+	Info       openapiInfo                                   `json:"info"`
+	Servers    []openapiServer                               `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openapiOperationObject `json:"paths"`
+	Components openapiComponents                             `json:"components"`
+}
+
+type openapiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openapiServer struct {
+	URL string `json:"url"`
+}
+
+type openapiComponents struct {
+	Schemas         map[string]*openapiSchema         `json:"schemas"`
+	SecuritySchemes map[string]*openapiSecurityScheme `json:"securitySchemes"`
+}
+
+type openapiSecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+type openapiOperationObject struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary"`
+	Parameters  []*openapiParameter         `json:"parameters,omitempty"`
+	RequestBody *openapiRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openapiResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openapiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openapiSchema `json:"schema"`
+}
+
+type openapiRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openapiMediaType `json:"content"`
+}
+
+type openapiResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openapiMediaType `json:"content,omitempty"`
+}
+
+type openapiMediaType struct {
+	Schema *openapiSchema `json:"schema,omitempty"`
+}
+
+type openapiSchema struct {
+	Ref              string                    `json:"$ref,omitempty"`
+	Type             string                    `json:"type,omitempty"`
+	Format           string                    `json:"format,omitempty"`
+	Description      string                    `json:"description,omitempty"`
+	Nullable         bool                      `json:"nullable,omitempty"`
+	Enum             []string                  `json:"enum,omitempty"`
+	Default          any                       `json:"default,omitempty"`
+	Minimum          *float64                  `json:"minimum,omitempty"`
+	ExclusiveMinimum bool                      `json:"exclusiveMinimum,omitempty"`
+	Maximum          *float64                  `json:"maximum,omitempty"`
+	ExclusiveMaximum bool                      `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int                      `json:"minLength,omitempty"`
+	MaxLength        *int                      `json:"maxLength,omitempty"`
+	Items            *openapiSchema            `json:"items,omitempty"`
+	Properties       map[string]*openapiSchema `json:"properties,omitempty"`
+	Required         []string                  `json:"required,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(multipart.FileHeader{})
+	typeQualifier  = regexp.MustCompile(`[\w./-]*\.`)
+)
+
+// openapiPath 把 gin 的 :name / *name 参数改写为 OpenAPI 的 {name}
+func openapiPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openapiIgnored gin 自带的静态文件处理器(js/css/img/favicon)和 HEAD 路由不写入文档
+func openapiIgnored(route gin.RouteInfo) bool {
+	return route.Method == http.MethodHead || strings.HasPrefix(route.Handler, "github.com/gin-gonic/gin.")
+}
+
+// OpenAPIMissingRoutes 返回已注册但没有在 openapiOperations 中登记的路由
+func OpenAPIMissingRoutes(routes gin.RoutesInfo) []string {
+	_, missing := buildOpenAPI(routes)
+	return missing
+}
+
+// buildOpenAPI 根据已注册的路由生成 OpenAPI 文档, 同时返回没有登记文档信息的路由
+func buildOpenAPI(routes gin.RoutesInfo) (*openapiDocument, []string) {
+	doc := &openapiDocument{
+		OpenAPI: openapiVersion,
+		Info:    openapiInfo{Title: "MyBlog API", Version: "1.0.0"},
+		Paths:   map[string]map[string]*openapiOperationObject{},
+		Components: openapiComponents{
+			Schemas: map[string]*openapiSchema{},
+			SecuritySchemes: map[string]*openapiSecurityScheme{
+				openapiSecurityName: {Type: "apiKey", In: "header", Name: "auth_token"},
+			},
+		},
+	}
+	var missing []string
+	for _, route := range routes {
+		if openapiIgnored(route) {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		operation := openapiOperations[key]
+		if operation == nil {
+			missing = append(missing, key)
+			continue
+		}
+		path, params := openapiPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openapiOperationObject{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = doc.buildOperation(params, operation)
+	}
+	sort.Strings(missing)
+	return doc, missing
+}
+
+func (doc *openapiDocument) buildOperation(params []string, operation *openapiOperation) *openapiOperationObject {
+	object := &openapiOperationObject{
+		Summary:   operation.Summary,
+		Responses: map[string]*openapiResponse{},
+	}
+	if len(operation.Tag) > 0 {
+		object.Tags = []string{operation.Tag}
+	}
+	for _, name := range params {
+		param := &openapiParameter{Name: name, In: "path", Required: true, Schema: &openapiSchema{Type: "string"}}
+		if known := openapiPathParams[name]; known != nil {
+			param.Description = known.Description
+			param.Schema = known.Schema
+		}
+		object.Parameters = append(object.Parameters, param)
+	}
+	object.Parameters = append(object.Parameters, operation.Query...)
+
+	if operation.Body != nil {
+		schema := doc.schemaOf(reflect.TypeOf(operation.Body))
+		object.RequestBody = &openapiRequestBody{Required: true, Content: map[string]*openapiMediaType{}}
+		if len(operation.Consumes) > 0 {
+			object.RequestBody.Content[operation.Consumes] = &openapiMediaType{Schema: schema}
+		} else {
+			// ShouldBind 按 Content-Type 选择解析方式, 表单字段名与 json tag 一致
+			object.RequestBody.Content["application/json"] = &openapiMediaType{Schema: schema}
+			object.RequestBody.Content["application/x-www-form-urlencoded"] = &openapiMediaType{Schema: schema}
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &openapiResponse{Description: http.StatusText(status)}
+	if status != http.StatusNoContent {
+		produces := operation.Produces
+		if len(produces) == 0 {
+			produces = "application/json"
+		}
+		media := &openapiMediaType{}
+		if operation.Response != nil {
+			media.Schema = doc.schemaOf(reflect.TypeOf(operation.Response))
+		} else if produces == "application/json" {
+			media.Schema = &openapiSchema{Type: "object"}
+		}
+		success.Content = map[string]*openapiMediaType{produces: media}
+	}
+	object.Responses[strconv.Itoa(status)] = success
+
+	// 错误统一为 apperr.ErrorResponse, 浏览器直接访问页面时返回错误页
+	errorResponse := func(status int) *openapiResponse {
+		content := map[string]*openapiMediaType{
+			"application/json": {Schema: doc.schemaOf(reflect.TypeOf(apperr.ErrorResponse{}))},
+		}
+		if operation.Produces == "text/html" {
+			content["text/html"] = &openapiMediaType{}
+		}
+		return &openapiResponse{Description: http.StatusText(status), Content: content}
+	}
+	if len(params) > 0 || len(operation.Query) > 0 || operation.Body != nil {
+		object.Responses["400"] = errorResponse(http.StatusBadRequest)
+	}
+	if operation.Auth {
+		object.Security = []map[string][]string{{openapiSecurityName: {}}}
+		object.Responses["401"] = errorResponse(http.StatusUnauthorized)
+	}
+	for _, status := range operation.Errors {
+		object.Responses[strconv.Itoa(status)] = errorResponse(status)
+	}
+	object.Responses["default"] = errorResponse(http.StatusInternalServerError)
+	return object
+}
+
+// schemaName 组件名去掉包路径, 泛型参数拼在后面, 如 ListResponse[*myblog/handler.BlogResource] 变为 ListResponseBlogResource
+func schemaName(t reflect.Type) string {
+	name := typeQualifier.ReplaceAllString(t.Name(), "")
+	return strings.Map(func(r rune) rune {
+		if r < '0' || (r > '9' && r < 'A') || (r > 'Z' && r < 'a') || r > 'z' {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// schemaOf 生成类型的 schema, 具名结构体放入 components 并返回引用
+func (doc *openapiDocument) schemaOf(t reflect.Type) *openapiSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &openapiSchema{Type: "string", Format: "date-time"}
+	case t == fileHeaderType:
+		return &openapiSchema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openapiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openapiSchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openapiSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openapiSchema{Type: "number"}
+	case reflect.String:
+		return &openapiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openapiSchema{Type: "array", Items: doc.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openapiSchema{Type: "object"}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return doc.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			doc.Components.Schemas[name] = &openapiSchema{} // 先占位, 防止递归类型死循环
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &openapiSchema{Ref: "#/components/schemas/" + name}
+	}
+	return &openapiSchema{}
+}
+
+func (doc *openapiDocument) structSchema(t reflect.Type) *openapiSchema {
+	schema := &openapiSchema{Type: "object", Properties: map[string]*openapiSchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && len(name) == 0 {
+			embedded := doc.structSchema(field.Type)
+			for key, value := range embedded.Properties {
+				schema.Properties[key] = value
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+
+		property := doc.schemaOf(field.Type)
+		if field.Type.Kind() == reflect.Pointer && len(property.Ref) == 0 {
+			property.Nullable = true
+		}
+		if applyBindingRules(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		} else if field.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty") && !isRequestField(field) {
+			// 响应结构中没有 omitempty 的字段总会输出
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// isRequestField 带 form tag 的是请求绑定结构的字段, 未标 required 的都是可选参数
+func isRequestField(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("form")
+	return ok || len(field.Tag.Get("binding")) > 0
+}
+
+// applyBindingRules 把 binding 约束转换为 schema 约束, 返回字段是否必填。
+// 字符串的 len/min/max/gt/lt 等约束的是长度, 与 validator 的语义一致
+func applyBindingRules(schema *openapiSchema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			required = true
+			continue
+		}
+		if name == "oneof" {
+			schema.Enum = strings.Fields(value)
+			continue
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		if schema.Type == "string" {
+			length := int(number)
+			switch name {
+			case "len":
+				schema.MinLength, schema.MaxLength = ptr(length), ptr(length)
+			case "min", "gte":
+				schema.MinLength = ptr(length)
+			case "gt":
+				schema.MinLength = ptr(length + 1)
+			case "max", "lte":
+				schema.MaxLength = ptr(length)
+			case "lt":
+				schema.MaxLength = ptr(length - 1)
+			}
+			continue
+		}
+		switch name {
+		case "min", "gte":
+			schema.Minimum = ptr(number)
+		case "gt":
+			schema.Minimum, schema.ExclusiveMinimum = ptr(number), true
+		case "max", "lte":
+			schema.Maximum = ptr(number)
+		case "lt":
+			schema.Maximum, schema.ExclusiveMaximum = ptr(number), true
+		}
+	}
+	return required
+}
+
+// NewOpenAPI 返回 OpenAPI 文档, routes 一般传 router.Routes; 路由注册完成后才会被访问, 首次请求时生成并缓存
+func NewOpenAPI(routes func() gin.RoutesInfo) gin.HandlerFunc {
+	var once sync.Once
+	var doc *openapiDocument
+	return func(ctx *gin.Context) {
+		once.Do(func() {
+			var missing []string
+			doc, missing = buildOpenAPI(routes())
+			if len(missing) > 0 {
+				zap.L().Warn("routes missing from openapi", zap.Strings("routes", missing))
+			}
+		})
+		response := *doc
+		response.Servers = []openapiServer{{URL: siteBaseURL(ctx)}}
+		ctx.JSON(http.StatusOK, &response)
+	}
+}
+
+func NewOpenAPIViewer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.HTML(http.StatusOK, "openapi.html", gin.H{"spec_url": "/openapi.json"})
+	}
+}
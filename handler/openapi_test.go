@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openapiPath("/blog/:bid/revisions/:rid/restore")
+	assert.Equal(t, "/blog/{bid}/revisions/{rid}/restore", path)
+	assert.Equal(t, []string{"bid", "rid"}, params)
+
+	path, params = openapiPath("/blog/public")
+	assert.Equal(t, "/blog/public", path)
+	assert.Empty(t, params)
+}
+
+func TestOpenAPIBindingConstraints(t *testing.T) {
+	doc, _ := buildOpenAPI(nil)
+
+	ref := doc.schemaOf(reflect.TypeOf(UpdateRequest{}))
+	assert.Equal(t, "#/components/schemas/UpdateRequest", ref.Ref)
+	schema := doc.Components.Schemas["UpdateRequest"]
+	assert.ElementsMatch(t, []string{"bid", "title", "article"}, schema.Required)
+	assert.Equal(t, "integer", schema.Properties["bid"].Type)
+	assert.Equal(t, 0.0, *schema.Properties["bid"].Minimum)
+	assert.True(t, schema.Properties["bid"].ExclusiveMinimum)
+	assert.True(t, schema.Properties["category"].Nullable)
+
+	doc.schemaOf(reflect.TypeOf(ApiLoginRequest{}))
+	pass := doc.Components.Schemas["ApiLoginRequest"].Properties["pass"]
+	assert.Equal(t, 32, *pass.MinLength)
+	assert.Equal(t, 32, *pass.MaxLength)
+
+	ref = doc.schemaOf(reflect.TypeOf(ListResponse[*BlogResource]{}))
+	assert.Equal(t, "#/components/schemas/ListResponseBlogResource", ref.Ref)
+	list := doc.Components.Schemas["ListResponseBlogResource"]
+	assert.Equal(t, "#/components/schemas/BlogResource", list.Properties["items"].Items.Ref)
+	assert.Equal(t, "#/components/schemas/PageInfo", list.Properties["page"].Ref)
+	assert.Equal(t, "date-time", doc.Components.Schemas["BlogResource"].Properties["update_time"].Format)
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/publish", NewBlogPublish())
+	router.DELETE("/api/v1/blogs/:bid", NewApiBlogDelete())
+	router.PUT("/api/v1/blogs/:bid", NewApiBlogUpdate())
+	router.GET("/undocumented", NewHome())
+	router.Static("/js", "views/js")
+
+	doc, missing := buildOpenAPI(router.Routes())
+	assert.Equal(t, []string{"GET /undocumented"}, missing)
+	assert.NotContains(t, doc.Paths, "/js/{filepath}")
+
+	publish := doc.Paths["/blog/publish"]["post"]
+	assert.Equal(t, []map[string][]string{{openapiSecurityName: {}}}, publish.Security)
+	assert.Contains(t, publish.RequestBody.Content, "application/json")
+	assert.Contains(t, publish.Responses, "401")
+	assert.Equal(t, "#/components/schemas/ErrorResponse", publish.Responses["400"].Content["application/json"].Schema.Ref)
+
+	remove := doc.Paths["/api/v1/blogs/{bid}"]["delete"]
+	assert.Equal(t, "bid", remove.Parameters[0].Name)
+	assert.Equal(t, "path", remove.Parameters[0].In)
+	assert.Nil(t, remove.Responses["204"].Content)
+	assert.Contains(t, remove.Responses["401"].Content, "application/json")
+	assert.Contains(t, remove.Responses, "400")
+	assert.Equal(t, http.StatusText(http.StatusNoContent), remove.Responses["204"].Description)
+
+	update := doc.Paths["/api/v1/blogs/{bid}"]["put"]
+	assert.Contains(t, update.Responses, "409")
+	assert.Contains(t, update.Responses, "428")
+	assert.Equal(t, "If-Match", update.Parameters[1].Name)
+	assert.Equal(t, "header", update.Parameters[1].In)
+	assert.Contains(t, doc.Components.Schemas["ApiBlogUpdateRequest"].Properties, "version")
+	assert.Contains(t, doc.Components.Schemas["ApiBlogUpdateRequest"].Required, "title")
+}
+
+func TestOpenAPISearchNotPaged(t *testing.T) {
+	// 搜索接口不分页, 规范中不能出现 limit/cursor, 否则生成的客户端会反复拿到同一页
+	for _, param := range openapiOperations["GET /blog/public/search"].Query {
+		assert.NotContains(t, []string{"limit", "cursor"}, param.Name)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePageQuery 读取 limit/cursor 查询参数, 参数不合法时已写回 400 并返回 false
+func parsePageQuery(ctx *gin.Context) (*database.PageQuery, bool) {
+	page, err := readPageQuery(ctx)
+	if err != nil {
+		apperr.Write(ctx, err)
+		return nil, false
+	}
+	return page, true
+}
+
+func readPageQuery(ctx *gin.Context) (*database.PageQuery, error) {
+	page := &database.PageQuery{
+		Limit:  database.DefaultPageSize,
+		Cursor: ctx.Query("cursor"),
+	}
+	if value := ctx.Query("limit"); len(value) > 0 {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > database.MaxPageSize {
+			return nil, apperr.InvalidField("limit", "max", "invalid limit")
+		}
+		page.Limit = limit
+	}
+	return page, nil
+}
+
+// pageURL 在当前请求地址上替换 cursor 与 limit, 保留其他筛选参数; cursor 为空时返回空串
+func pageURL(ctx *gin.Context, limit int, cursor string) string {
+	if len(cursor) == 0 {
+		return ""
+	}
+	values := ctx.Request.URL.Query()
+	values.Set("cursor", cursor)
+	if limit != database.DefaultPageSize {
+		values.Set("limit", strconv.Itoa(limit))
+	} else {
+		values.Del("limit")
+	}
+	return ctx.Request.URL.Path + "?" + values.Encode()
+}
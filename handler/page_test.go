@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlogListInvalidLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/list/:uid", NewBlogList())
+
+	for _, limit := range []string{"abc", "0", "-1", "101"} {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/blog/list/1?limit="+limit, nil)
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, http.StatusBadRequest, writer.Code, limit)
+		assert.Contains(t, writer.Body.String(), "invalid limit")
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/blog/public?tag=go&cursor=old&limit=20", nil)
+
+	assert.Equal(t, "", pageURL(ctx, 20, ""))
+	assert.Equal(t, "/blog/public?cursor=abc&tag=go", pageURL(ctx, 20, "abc"))
+	assert.Equal(t, "/blog/public?cursor=abc&limit=5&tag=go", pageURL(ctx, 5, "abc"))
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/handler/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NewPublicBlogReactions 公开博客的表态统计, 请求带有效 token 时同时返回当前用户的表态
+func NewPublicBlogReactions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		if !database.IsBlogPublic(bid) {
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		writeReactionSummary(ctx, bid, middleware.GetLoginUid(ctx))
+	}
+}
+
+// NewPublicBlogReactionSet 添加 (PUT) 或取消 (DELETE) 表态, 重复请求的结果相同
+func NewPublicBlogReactionSet(add bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		kind := ctx.Param("kind")
+
+		loginUidValue, ok := ctx.Get("uid")
+		if !ok {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+		loginUid, ok := loginUidValue.(int)
+		if !ok || loginUid <= 0 {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+
+		if add {
+			err = database.AddBlogReaction(bid, loginUid, kind)
+		} else {
+			err = database.RemoveBlogReaction(bid, loginUid, kind)
+		}
+		if err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			zap.L().Error("set blog reaction failed", zap.Int("bid", bid), zap.Int("uid", loginUid), zap.String("kind", kind), zap.Bool("add", add), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("set reaction failed"))
+			return
+		}
+		writeReactionSummary(ctx, bid, loginUid)
+	}
+}
+
+func writeReactionSummary(ctx *gin.Context, bid int, uid int) {
+	summary, err := database.GetBlogReactionSummary(bid, uid)
+	if err != nil {
+		apperr.Write(ctx, apperr.Internal("get reactions failed"))
+		return
+	}
+	ctx.JSON(http.StatusOK, summary)
+}
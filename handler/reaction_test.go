@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myblog/database"
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReactionTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/public/:bid/reactions", NewPublicBlogReactions())
+	router.PUT("/blog/public/:bid/reactions/:kind", middleware.Auth(), NewPublicBlogReactionSet(true))
+	router.DELETE("/blog/public/:bid/reactions/:kind", middleware.Auth(), NewPublicBlogReactionSet(false))
+	return router
+}
+
+func TestPublicBlogReactionsInvalidBid(t *testing.T) {
+	router := newReactionTestRouter()
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/blog/public/abc/reactions", nil))
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+
+	writer = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPut, "/blog/public/abc/reactions/like", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestPublicBlogReactionSetRequiresAuth(t *testing.T) {
+	router := newReactionTestRouter()
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(method, "/blog/public/1/reactions/like", nil))
+		assert.Equal(t, http.StatusUnauthorized, writer.Code, method)
+	}
+}
+
+func TestPublicBlogReactionSetInvalidKind(t *testing.T) {
+	router := newReactionTestRouter()
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(method, "/blog/public/1/reactions/dislike", nil)
+		request.Header.Set("auth_token", newCommentTestToken(t, 1))
+		router.ServeHTTP(writer, request)
+		assert.Equal(t, http.StatusBadRequest, writer.Code, method)
+		assert.Contains(t, writer.Body.String(), "invalid reaction")
+	}
+}
+
+func TestIsValidReaction(t *testing.T) {
+	for _, reaction := range database.Reactions {
+		assert.True(t, database.IsValidReaction(reaction.Kind), reaction.Kind)
+	}
+	assert.False(t, database.IsValidReaction(""))
+	assert.False(t, database.IsValidReaction("LIKE"))
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/util"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// loadOwnedBlog 解析路径中的 bid 并校验登录用户是否为作者, 失败时已写回响应并返回 nil
+func loadOwnedBlog(ctx *gin.Context) *database.Blog {
+	bid, err := strconv.Atoi(ctx.Param("bid"))
+	if err != nil {
+		apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+		return nil
+	}
+
+	loginUidValue, ok := ctx.Get("uid")
+	if !ok {
+		apperr.Write(ctx, apperr.ErrAuthFailed)
+		return nil
+	}
+	loginUid, ok := loginUidValue.(int)
+	if !ok || loginUid <= 0 {
+		apperr.Write(ctx, apperr.ErrAuthFailed)
+		return nil
+	}
+
+	blog := database.GetBlogById(bid)
+	if blog == nil {
+		apperr.Write(ctx, apperr.ErrBlogNotExist)
+		return nil
+	}
+	if blog.UserId != loginUid {
+		apperr.Write(ctx, apperr.ErrNoPermission.WithMessage("no permission to access blog"))
+		return nil
+	}
+	return blog
+}
+
+func NewBlogRevisions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		revisions := database.GetBlogRevisions(blog.Id)
+		result := make([]gin.H, 0, len(revisions))
+		for _, revision := range revisions {
+			result = append(result, gin.H{
+				"id":           revision.Id,
+				"title":        revision.Title,
+				"restore_from": revision.RestoreFrom,
+				"create_time":  revision.CreateTime.Format("2006-01-02 15:04:05"),
+			})
+		}
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func NewBlogRevisionDiff() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		from, err := strconv.Atoi(ctx.Query("from"))
+		if err != nil || from <= 0 {
+			apperr.Write(ctx, apperr.InvalidField("from", "gt", "invalid parameter"))
+			return
+		}
+		to, err := strconv.Atoi(ctx.Query("to"))
+		if err != nil || to <= 0 {
+			apperr.Write(ctx, apperr.InvalidField("to", "gt", "invalid parameter"))
+			return
+		}
+
+		fromRevision := database.GetBlogRevisionById(blog.Id, from)
+		toRevision := database.GetBlogRevisionById(blog.Id, to)
+		if fromRevision == nil || toRevision == nil {
+			apperr.Write(ctx, apperr.ErrRevisionNotExist)
+			return
+		}
+
+		lines, err := util.LineDiff(fromRevision.Article, toRevision.Article)
+		if err != nil {
+			apperr.Write(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"from":       fromRevision.Id,
+			"to":         toRevision.Id,
+			"from_title": fromRevision.Title,
+			"to_title":   toRevision.Title,
+			"lines":      lines,
+		})
+	}
+}
+
+func NewBlogRevisionRestore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		rid, err := strconv.Atoi(ctx.Param("rid"))
+		if err != nil || rid <= 0 {
+			apperr.Write(ctx, apperr.InvalidField("rid", "int", "invalid revision id"))
+			return
+		}
+
+		err = database.RestoreBlogRevision(blog.Id, rid, blog.UserId)
+		if err != nil {
+			if errors.Is(err, database.ErrRevisionNotExist) {
+				apperr.Write(ctx, apperr.ErrRevisionNotExist)
+				return
+			}
+			zap.L().Error("restore blog revision failed", zap.Int("bid", blog.Id), zap.Int("rid", rid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("restore revision failed"))
+			return
+		}
+		ctx.String(http.StatusOK, "restore revision success")
+	}
+}
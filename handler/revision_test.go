@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlogRevisionsInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/:bid/revisions", middleware.Auth(), NewBlogRevisions())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/blog/abc/revisions", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestNewBlogRevisionsAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/:bid/revisions", middleware.Auth(), NewBlogRevisions())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/blog/1/revisions", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	assert.Contains(t, writer.Body.String(), "auth failed")
+}
+
+func TestNewBlogRevisionRestoreAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/:bid/revisions/:rid/restore", middleware.Auth(), NewBlogRevisionRestore())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/1/revisions/1/restore", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	assert.Contains(t, writer.Body.String(), "auth failed")
+}
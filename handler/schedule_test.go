@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScheduleTime(t *testing.T) {
+	empty, err := parseScheduleTime("  ")
+	assert.NoError(t, err)
+	assert.Nil(t, empty)
+
+	expect := time.Date(2030, 1, 2, 8, 30, 0, 0, time.Local)
+	for _, value := range []string{"2030-01-02 08:30:00", "2030-01-02T08:30", expect.Format(time.RFC3339)} {
+		got, err := parseScheduleTime(value)
+		if assert.NoError(t, err, value) {
+			assert.True(t, expect.Equal(*got), value)
+		}
+	}
+
+	_, err = parseScheduleTime("tomorrow")
+	assert.Error(t, err)
+}
+
+func TestNewBlogPublishInvalidPublishAt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/publish", middleware.Auth(), NewBlogPublish())
+
+	form := url.Values{"bid": {"1"}, "publish_at": {"not-a-time"}}
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/publish", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid publish_at")
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/util"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSearchQueryLength = 100
+	searchSnippetWidth   = 120
+)
+
+func NewPublicBlogSearch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		query := strings.TrimSpace(ctx.Query("q"))
+		if len(query) == 0 || len([]rune(query)) > maxSearchQueryLength {
+			apperr.Write(ctx, apperr.InvalidField("q", "max", "invalid parameter"))
+			return
+		}
+
+		terms := util.SearchTerms(query)
+		blogs, err := database.SearchPublicBlogs(query)
+		if err != nil {
+			apperr.Write(ctx, apperr.Internal("search failed"))
+			return
+		}
+		results := make([]gin.H, 0, len(blogs))
+		for _, blog := range blogs {
+			results = append(results, gin.H{
+				"id":          blog.Id,
+				"title":       blog.Title,
+				"title_html":  util.Highlight(blog.Title, terms),
+				"snippet":     util.Snippet(util.MarkdownToText(blog.Article), terms, searchSnippetWidth),
+				"user_name":   blog.UserName,
+				"update_time": blog.UpdateTime.Format("2006-01-02 15:04:05"),
+				"score":       blog.Score,
+			})
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"query":   query,
+			"count":   len(results), // 本次返回的条数, 不是匹配的总数
+			"results": results,
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPublicBlogSearchInvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/blog/public/search", NewPublicBlogSearch())
+
+	for _, query := range []string{"", "q=", "q=%20%20", "q=" + strings.Repeat("a", 101)} {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/blog/public/search?"+query, nil)
+		router.ServeHTTP(writer, request)
+
+		assert.Equal(t, http.StatusBadRequest, writer.Code, query)
+		assert.Contains(t, writer.Body.String(), "invalid parameter")
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/xml"
+	"errors"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// siteBaseURL 生成绝对链接的前缀, 未配置 site.base_url 时取自请求的 Host。
+// 只采用 site.trusted_proxies 中的代理转发来的 X-Forwarded-Proto, 且只接受 http 和 https;
+// 前缀取自请求时会写入页面内容, 使用它的页面需要把它计入 ETag
+func siteBaseURL(ctx *gin.Context) string {
+	if base := blogConfig.GetString("site.base_url"); len(base) > 0 {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(ctx.GetHeader("X-Forwarded-Proto")); (proto == "http" || proto == "https") && fromTrustedProxy(ctx) {
+		scheme = proto
+	}
+	return scheme + "://" + ctx.Request.Host
+}
+
+// TrustedProxies site.trusted_proxies 中的代理地址, 路由据此决定是否采用 X-Forwarded-For; 未配置时返回 nil, 不信任任何代理
+func TrustedProxies() []string {
+	proxies := blogConfig.GetStringSlice("site.trusted_proxies")
+	if len(proxies) == 0 {
+		return nil
+	}
+	return proxies
+}
+
+// fromTrustedProxy 请求是否直接来自 site.trusted_proxies 中的地址, 配置项可以是 IP 或 CIDR
+func fromTrustedProxy(ctx *gin.Context) bool {
+	remote, err := netip.ParseAddr(ctx.RemoteIP())
+	if err != nil {
+		return false
+	}
+	remote = remote.Unmap()
+	for _, proxy := range TrustedProxies() {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			if prefix.Contains(remote) {
+				return true
+			}
+		} else if addr, err := netip.ParseAddr(proxy); err == nil && addr.Unmap() == remote {
+			return true
+		}
+	}
+	return false
+}
+
+// publicBlogPath 公开博客的访问路径, 有别名时优先使用别名
+func publicBlogPath(bid int, slug string) string {
+	if len(slug) > 0 {
+		return "/p/" + slug
+	}
+	return "/blog/public/" + strconv.Itoa(bid)
+}
+
+// NewPublicBlogBySlug 通过别名访问公开博客, 旧别名 301 跳转到当前别名
+func NewPublicBlogBySlug() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		slug := ctx.Param("slug")
+		bid, current, err := database.ResolveSlug(slug)
+		if err != nil {
+			if !errors.Is(err, database.ErrSlugNotExist) {
+				zap.L().Error("resolve slug failed", zap.String("slug", slug), zap.Error(err))
+			}
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		validator, ok := publicBlogValidator(ctx, bid)
+		if !ok {
+			return
+		}
+		if current != slug {
+			ctx.Redirect(http.StatusMovedPermanently, "/p/"+current)
+			return
+		}
+		recordBlogView(ctx, bid)
+		if validator.notModified(ctx) {
+			return
+		}
+		blog := database.GetPublicBlogById(bid)
+		if blog == nil {
+			apperr.Write(ctx, apperr.ErrPublicBlogNotExist)
+			return
+		}
+		validator.writeHeaders(ctx)
+		renderPublicBlog(ctx, blog)
+	}
+}
+
+func NewSitemap() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		base := siteBaseURL(ctx)
+		urlSet := &sitemapURLSet{
+			URLs: []sitemapURL{},
+		}
+		for _, entry := range database.GetSitemapEntries() {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{
+				Loc:     base + publicBlogPath(entry.Id, entry.Slug),
+				LastMod: entry.UpdateTime.Format(time.RFC3339),
+			})
+		}
+		body, err := xml.MarshalIndent(urlSet, "", "  ")
+		if err != nil {
+			zap.L().Error("marshal sitemap failed", zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("load sitemap failed"))
+			return
+		}
+		ctx.Data(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), body...))
+	}
+}
+
+// NewRobots 只允许抓取公开页面, 作者的私有页面和写接口都在 /blog/ 下
+func NewRobots() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var b strings.Builder
+		b.WriteString("User-agent: *\n")
+		b.WriteString("Allow: /p/\n")
+		b.WriteString("Allow: /blog/public\n")
+		b.WriteString("Disallow: /blog/\n")
+		b.WriteString("\nSitemap: " + siteBaseURL(ctx) + "/sitemap.xml\n")
+		ctx.String(http.StatusOK, b.String())
+	}
+}
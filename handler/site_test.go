@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicBlogPath(t *testing.T) {
+	assert.Equal(t, "/p/hello-world", publicBlogPath(3, "hello-world"))
+	assert.Equal(t, "/blog/public/3", publicBlogPath(3, ""))
+}
+
+func TestNewRobots(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/robots.txt", NewRobots())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/robots.txt", nil)
+	request.RemoteAddr = "127.0.0.1:12345"
+	request.Header.Set("X-Forwarded-Proto", "https")
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Body.String(), "Allow: /p/\n")
+	assert.Contains(t, writer.Body.String(), "Disallow: /blog/\n")
+	assert.Contains(t, writer.Body.String(), "Sitemap: https://example.com/sitemap.xml")
+}
+
+func TestSiteBaseURLForwardedProto(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/base", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, siteBaseURL(ctx))
+	})
+	get := func(remoteAddr string, proto string) string {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/base", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("X-Forwarded-Proto", proto)
+		router.ServeHTTP(writer, request)
+		return writer.Body.String()
+	}
+
+	assert.Equal(t, "https://example.com", get("127.0.0.1:12345", "https"))
+	assert.Equal(t, "https://example.com", get("[::1]:12345", "HTTPS"))
+	// 不在 site.trusted_proxies 中的客户端不能指定协议
+	assert.Equal(t, "http://example.com", get("203.0.113.7:12345", "https"))
+	// 只接受 http 和 https
+	assert.Equal(t, "http://example.com", get("127.0.0.1:12345", "javascript"))
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func NewBlogDelete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blog := loadOwnedBlog(ctx)
+		if blog == nil {
+			return
+		}
+
+		err := database.DeleteBlog(blog.Id, blog.UserId)
+		if err != nil {
+			if errors.Is(err, database.ErrBlogNotExist) {
+				apperr.Write(ctx, apperr.ErrBlogNotExist)
+				return
+			}
+			zap.L().Error("delete blog failed", zap.Int("bid", blog.Id), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("delete blog failed"))
+			return
+		}
+		ctx.String(http.StatusOK, "delete blog success")
+	}
+}
+
+func NewBlogRestore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+
+		loginUidValue, ok := ctx.Get("uid")
+		if !ok {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+		loginUid, ok := loginUidValue.(int)
+		if !ok || loginUid <= 0 {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+
+		// 回收站里只会有自己的博客, 不存在或不属于当前用户一律按不存在处理
+		err = database.RestoreBlog(bid, loginUid)
+		if err != nil {
+			if errors.Is(err, database.ErrBlogNotExist) {
+				apperr.Write(ctx, apperr.ErrBlogNotExist)
+				return
+			}
+			zap.L().Error("restore blog failed", zap.Int("bid", bid), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("restore blog failed"))
+			return
+		}
+		ctx.String(http.StatusOK, "restore blog success")
+	}
+}
+
+func NewBlogTrash() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUidValue, ok := ctx.Get("uid")
+		if !ok {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+		loginUid, ok := loginUidValue.(int)
+		if !ok || loginUid <= 0 {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+
+		blogs := database.GetTrashedBlogs(loginUid)
+		result := make([]gin.H, 0, len(blogs))
+		for _, blog := range blogs {
+			result = append(result, gin.H{
+				"id":          blog.Id,
+				"title":       blog.Title,
+				"delete_time": blog.DeleteTime.Format("2006-01-02 15:04:05"),
+			})
+		}
+		ctx.JSON(http.StatusOK, result)
+	}
+}
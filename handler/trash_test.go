@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBlogDeleteInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/blog/:bid", middleware.Auth(), NewBlogDelete())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/blog/not-number", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
+
+func TestNewBlogDeleteAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/blog/:bid", middleware.Auth(), NewBlogDelete())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/blog/1", nil)
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	assert.Contains(t, writer.Body.String(), "auth failed")
+}
+
+func TestNewBlogRestoreInvalidBid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/:bid/restore", middleware.Auth(), NewBlogRestore())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/not-number/restore", nil)
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid blog id")
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"myblog/storage"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const maxUploadFileName = 100
+
+// uploadExtensions 支持的文件类型及其扩展名, 实际允许的类型由 upload.allowed_types 配置。
+// 不支持 SVG 等可以内嵌脚本的类型
+var uploadExtensions = map[string]string{
+	"image/png":       "png",
+	"image/jpeg":      "jpg",
+	"image/gif":       "gif",
+	"image/webp":      "webp",
+	"application/pdf": "pdf",
+}
+
+var uploadNamePattern = regexp.MustCompile(`^([0-9a-f]{64})\.([a-z0-9]+)$`)
+
+func uploadURL(upload *database.Upload) string {
+	return "/upload/" + upload.Hash + "." + upload.Ext
+}
+
+// uploadStorageKey 按哈希前缀分两级目录, 避免单个目录下文件过多
+func uploadStorageKey(hash, ext string) string {
+	return hash[:2] + "/" + hash[2:4] + "/" + hash + "." + ext
+}
+
+// detectUploadType 根据文件内容而不是客户端声明的 Content-Type 判断类型, 不允许的类型返回空串
+func detectUploadType(data []byte) string {
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if _, ok := uploadExtensions[mimeType]; !ok {
+		return ""
+	}
+	if !slices.Contains(blogConfig.GetStringSlice("upload.allowed_types"), mimeType) {
+		return ""
+	}
+	return mimeType
+}
+
+func NewUpload() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUidValue, ok := ctx.Get("uid")
+		if !ok {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+		loginUid, ok := loginUidValue.(int)
+		if !ok || loginUid <= 0 {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+
+		maxSize := int64(blogConfig.GetSizeInBytes("upload.max_size"))
+		// 为 multipart 的边界和其他字段留出余量, 文件本身的大小在下面单独校验
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+64<<10)
+		header, err := ctx.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				apperr.Write(ctx, apperr.ErrTooLarge.WithMessage("file too large"))
+				return
+			}
+			apperr.Write(ctx, apperr.InvalidField("file", "required", "invalid parameter"))
+			return
+		}
+		if header.Size > maxSize {
+			apperr.Write(ctx, apperr.ErrTooLarge.WithMessage("file too large"))
+			return
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			zap.L().Error("open upload file failed", zap.Error(err))
+			apperr.Write(ctx, apperr.InvalidField("file", "required", "invalid parameter"))
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+		if err != nil {
+			zap.L().Error("read upload file failed", zap.Error(err))
+			apperr.Write(ctx, apperr.InvalidField("file", "required", "invalid parameter"))
+			return
+		}
+		if int64(len(data)) > maxSize {
+			apperr.Write(ctx, apperr.ErrTooLarge.WithMessage("file too large"))
+			return
+		}
+		if len(data) == 0 {
+			apperr.Write(ctx, apperr.InvalidField("file", "required", "empty file"))
+			return
+		}
+		mimeType := detectUploadType(data)
+		if len(mimeType) == 0 {
+			apperr.Write(ctx, apperr.ErrUnsupportedType)
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		upload := &database.Upload{
+			Hash:     hex.EncodeToString(sum[:]),
+			Size:     int64(len(data)),
+			MimeType: mimeType,
+			Ext:      uploadExtensions[mimeType],
+		}
+		upload.StorageKey = uploadStorageKey(upload.Hash, upload.Ext)
+
+		// 内容相同的文件只存一份, 已存在时跳过写入
+		store := storage.Default()
+		exists, err := store.Exists(upload.StorageKey)
+		if err == nil && !exists {
+			err = store.Put(upload.StorageKey, bytes.NewReader(data), upload.Size, mimeType)
+		}
+		if err != nil {
+			zap.L().Error("store upload file failed", zap.String("key", upload.StorageKey), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("upload failed"))
+			return
+		}
+
+		fileName := filepath.Base(header.Filename)
+		if runes := []rune(fileName); len(runes) > maxUploadFileName {
+			fileName = string(runes[:maxUploadFileName])
+		}
+		if err := database.SaveUpload(upload, loginUid, fileName); err != nil {
+			zap.L().Error("save upload failed", zap.String("hash", upload.Hash), zap.Int("uid", loginUid), zap.Error(err))
+			apperr.Write(ctx, apperr.Internal("upload failed"))
+			return
+		}
+
+		url := uploadURL(upload)
+		alt := strings.NewReplacer("[", "", "]", "").Replace(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+		markdown := "[" + alt + "](" + url + ")"
+		if strings.HasPrefix(upload.MimeType, "image/") {
+			markdown = "!" + markdown
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"url":       url,
+			"hash":      upload.Hash,
+			"size":      upload.Size,
+			"mime_type": upload.MimeType,
+			"file_name": fileName,
+			"markdown":  markdown,
+		})
+	}
+}
+
+// NewUploadFile 按内容哈希提供上传的文件, 地址不变内容就不变, 可以长期缓存
+func NewUploadFile() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		match := uploadNamePattern.FindStringSubmatch(ctx.Param("name"))
+		if match == nil {
+			apperr.Write(ctx, apperr.ErrFileNotExist)
+			return
+		}
+		upload := database.GetUploadByHash(match[1])
+		if upload == nil || upload.Ext != match[2] {
+			apperr.Write(ctx, apperr.ErrFileNotExist)
+			return
+		}
+
+		etag := `"` + upload.Hash + `"`
+		ctx.Header("ETag", etag)
+		ctx.Header("Cache-Control", "public, max-age=31536000, immutable")
+		if ctx.GetHeader("If-None-Match") == etag {
+			ctx.AbortWithStatus(http.StatusNotModified)
+			return
+		}
+
+		reader, err := storage.Default().Get(upload.StorageKey)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotExist) {
+				zap.L().Error("read upload file failed", zap.String("key", upload.StorageKey), zap.Error(err))
+			}
+			apperr.Write(ctx, apperr.ErrFileNotExist)
+			return
+		}
+		defer reader.Close()
+
+		headers := map[string]string{"X-Content-Type-Options": "nosniff"}
+		if !strings.HasPrefix(upload.MimeType, "image/") {
+			headers["Content-Disposition"] = "attachment"
+		}
+		ctx.DataFromReader(http.StatusOK, upload.Size, upload.MimeType, reader, headers)
+	}
+}
+
+// NewUploadList 列出当前用户上传过的文件
+func NewUploadList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		loginUidValue, ok := ctx.Get("uid")
+		if !ok {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+		loginUid, ok := loginUidValue.(int)
+		if !ok || loginUid <= 0 {
+			apperr.Write(ctx, apperr.ErrAuthFailed)
+			return
+		}
+
+		uploads := database.GetUploadsByUser(loginUid)
+		items := make([]gin.H, 0, len(uploads))
+		for _, upload := range uploads {
+			items = append(items, gin.H{
+				"url":         uploadURL(&upload.Upload),
+				"hash":        upload.Hash,
+				"size":        upload.Size,
+				"mime_type":   upload.MimeType,
+				"file_name":   upload.FileName,
+				"upload_time": upload.UploadTime.Format("2006-01-02 15:04:05"),
+			})
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"uploads": items,
+			"total":   len(items),
+		})
+	}
+}
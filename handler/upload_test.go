@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newUploadRequest(t *testing.T, fileName string, content []byte) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", fileName)
+	assert.NoError(t, err)
+	part.Write(content)
+	assert.NoError(t, writer.Close())
+
+	request := httptest.NewRequest(http.MethodPost, "/upload", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+func TestDetectUploadType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	assert.Equal(t, "image/png", detectUploadType(png))
+	assert.Equal(t, "application/pdf", detectUploadType([]byte("%PDF-1.4\n")))
+	assert.Empty(t, detectUploadType([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)))
+	assert.Empty(t, detectUploadType([]byte("<html><script>alert(1)</script></html>")))
+}
+
+func TestNewUploadAuthFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/upload", middleware.Auth(), NewUpload())
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, newUploadRequest(t, "a.png", []byte("x")))
+
+	assert.Equal(t, http.StatusUnauthorized, writer.Code)
+}
+
+func TestNewUploadUnsupportedType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/upload", middleware.Auth(), NewUpload())
+
+	writer := httptest.NewRecorder()
+	// 文件名声称是图片, 但内容是 HTML
+	request := newUploadRequest(t, "evil.png", []byte("<html><script>alert(1)</script></html>"))
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, writer.Code)
+	assert.Contains(t, writer.Body.String(), "unsupported file type")
+}
+
+func TestNewUploadTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/upload", middleware.Auth(), NewUpload())
+
+	maxSize := blogConfig.GetSizeInBytes("upload.max_size")
+	writer := httptest.NewRecorder()
+	request := newUploadRequest(t, "big.png", make([]byte, maxSize+1))
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	router.ServeHTTP(writer, request)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, writer.Code)
+}
+
+func TestNewUploadFileInvalidName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/upload/:name", NewUploadFile())
+
+	for _, name := range []string{"abc.png", "..%2Fconfig.yaml", "ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789.png"} {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/upload/"+name, nil))
+		assert.Equal(t, http.StatusNotFound, writer.Code, name)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"myblog/database"
+	"myblog/handler/apperr"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 博客编辑使用乐观锁: 详情接口返回 version 和 ETag, 更新时通过 version 字段或 If-Match 请求头传回,
+// 期间博客被别处修改过则返回 409 和服务端的当前内容
+
+// BlogConflictDetail 版本冲突时放在错误的 detail 中, 编辑器据此展示合并视图
+type BlogConflictDetail struct {
+	Version    int       `json:"version"`
+	Title      string    `json:"title"`
+	Article    string    `json:"article"`
+	UpdateTime time.Time `json:"update_time"`
+}
+
+func blogETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch 解析 blogETag 生成的 ETag, 兼容弱校验前缀 W/
+func parseIfMatch(header string) (int, bool) {
+	value := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// expectedBlogVersion 读取客户端编辑时基于的版本, 请求体中的 version 优先于 If-Match。
+// 两者都没有时返回 428, 不合法时返回 400, 此时已写回响应
+func expectedBlogVersion(ctx *gin.Context, version int) (int, bool) {
+	if version > 0 {
+		return version, true
+	}
+	header := ctx.GetHeader("If-Match")
+	if len(header) == 0 {
+		apperr.Write(ctx, apperr.ErrVersionRequired)
+		return 0, false
+	}
+	version, ok := parseIfMatch(header)
+	if !ok {
+		apperr.Write(ctx, apperr.InvalidField("If-Match", "etag", "invalid If-Match header"))
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionConflict 返回 409, 带上服务端当前的版本和内容
+func writeVersionConflict(ctx *gin.Context, bid int) {
+	current := database.GetBlogById(bid)
+	if current == nil {
+		apperr.Write(ctx, apperr.ErrBlogNotExist)
+		return
+	}
+	ctx.Header("ETag", blogETag(current.Version))
+	apperr.Write(ctx, apperr.ErrVersionConflict.WithDetail(&BlogConflictDetail{
+		Version:    current.Version,
+		Title:      current.Title,
+		Article:    current.Article,
+		UpdateTime: current.UpdateTime,
+	}))
+}
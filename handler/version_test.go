@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"myblog/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version, ok := parseIfMatch(blogETag(3))
+	assert.True(t, ok)
+	assert.Equal(t, 3, version)
+
+	version, ok = parseIfMatch(` W/"12" `)
+	assert.True(t, ok)
+	assert.Equal(t, 12, version)
+
+	for _, header := range []string{"3", `"abc"`, `"0"`, `"`, "*", `"1", "2"`} {
+		_, ok := parseIfMatch(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func newBlogUpdateTestRequest(t *testing.T, form url.Values, ifMatch string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/blog/update", middleware.Auth(), NewBlogUpdate())
+
+	writer := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/blog/update", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("auth_token", newCommentTestToken(t, 1))
+	if len(ifMatch) > 0 {
+		request.Header.Set("If-Match", ifMatch)
+	}
+	router.ServeHTTP(writer, request)
+	return writer
+}
+
+func TestNewBlogUpdateVersionRequired(t *testing.T) {
+	form := url.Values{"bid": {"1"}, "title": {"title"}, "article": {"article"}}
+	writer := newBlogUpdateTestRequest(t, form, "")
+
+	assert.Equal(t, http.StatusPreconditionRequired, writer.Code)
+	assert.Contains(t, writer.Body.String(), "version_required")
+}
+
+func TestNewBlogUpdateInvalidIfMatch(t *testing.T) {
+	form := url.Values{"bid": {"1"}, "title": {"title"}, "article": {"article"}}
+	writer := newBlogUpdateTestRequest(t, form, "*")
+
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "invalid If-Match header")
+}
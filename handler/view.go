@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"myblog/database"
+	"myblog/handler/apperr"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// botMarkers User-Agent 中出现这些片段时视为爬虫或脚本, 不计入阅读量
+var botMarkers = []string{
+	"bot", "spider", "crawl", "slurp", "preview", "headless", "lighthouse",
+	"curl", "wget", "python", "go-http-client", "java/", "okhttp", "httpclient",
+}
+
+// isBot 粗略识别明显的爬虫, 没有 User-Agent 的请求也视为爬虫
+func isBot(userAgent string) bool {
+	userAgent = strings.ToLower(strings.TrimSpace(userAgent))
+	if len(userAgent) == 0 {
+		return true
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(userAgent, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrefetch 浏览器预加载的请求用户未必会真正打开
+func isPrefetch(ctx *gin.Context) bool {
+	for _, header := range []string{"Sec-Purpose", "Purpose", "X-Moz"} {
+		if strings.Contains(strings.ToLower(ctx.GetHeader(header)), "prefetch") {
+			return true
+		}
+	}
+	return false
+}
+
+// visitorId 访客的匿名标识, 由 IP 和 User-Agent 摘要得到, 不保存原始信息;
+// IP 取自 ClientIP, 路由只信任 site.trusted_proxies 转发的 X-Forwarded-For
+func visitorId(ctx *gin.Context) string {
+	hash := sha1.New()
+	hash.Write([]byte(ctx.ClientIP() + "|" + ctx.Request.UserAgent()))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordBlogView 记录一次公开博客的阅读, 失败只记日志, 不影响页面的返回
+func recordBlogView(ctx *gin.Context, bid int) {
+	if isBot(ctx.Request.UserAgent()) || isPrefetch(ctx) {
+		return
+	}
+	if err := database.RecordBlogView(bid, visitorId(ctx), time.Now()); err != nil {
+		zap.L().Error("record blog view failed", zap.Int("bid", bid), zap.Error(err))
+	}
+}
+
+// NewPublicBlogViews 公开博客的累计阅读量, 与详情页分开请求, 详情页因此可以继续使用缓存
+func NewPublicBlogViews() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		bid, err := strconv.Atoi(ctx.Param("bid"))
+		if err != nil {
+			apperr.Write(ctx, apperr.InvalidField("bid", "int", "invalid blog id"))
+			return
+		}
+		if _, err := database.GetPublicBlogStamp(bid); err != nil {
+			if apperr.IsClientError(err) {
+				apperr.Write(ctx, err)
+				return
+			}
+			apperr.Write(ctx, apperr.Internal("get public blog failed"))
+			return
+		}
+		ctx.JSON(http.StatusOK, database.GetBlogViewTotal(bid))
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBot(t *testing.T) {
+	for _, userAgent := range []string{
+		"",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0 Safari/537.36",
+		"curl/8.5.0",
+		"python-requests/2.31.0",
+		"Go-http-client/1.1",
+	} {
+		assert.True(t, isBot(userAgent), userAgent)
+	}
+	for _, userAgent := range []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+	} {
+		assert.False(t, isBot(userAgent), userAgent)
+	}
+}
+
+func TestVisitorId(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newContext := func(remoteAddr, userAgent string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/blog/public/1", nil)
+		ctx.Request.RemoteAddr = remoteAddr
+		ctx.Request.Header.Set("User-Agent", userAgent)
+		return ctx
+	}
+
+	id := visitorId(newContext("10.0.0.1:1234", "Chrome"))
+	assert.Regexp(t, `^[0-9a-f]{40}$`, id)
+	assert.Equal(t, id, visitorId(newContext("10.0.0.1:5678", "Chrome")))
+	assert.NotEqual(t, id, visitorId(newContext("10.0.0.2:1234", "Chrome")))
+	assert.NotEqual(t, id, visitorId(newContext("10.0.0.1:1234", "Firefox")))
+}
+
+func TestVisitorIdIgnoresUntrustedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	assert.NoError(t, engine.SetTrustedProxies(TrustedProxies()))
+	newContext := func(remoteAddr, forwardedFor string) *gin.Context {
+		ctx := gin.CreateTestContextOnly(httptest.NewRecorder(), engine)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/blog/public/1", nil)
+		ctx.Request.RemoteAddr = remoteAddr
+		ctx.Request.Header.Set("User-Agent", "Chrome")
+		if len(forwardedFor) > 0 {
+			ctx.Request.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return ctx
+	}
+
+	// 伪造 X-Forwarded-For 不能刷出新的访客
+	id := visitorId(newContext("10.0.0.1:1234", ""))
+	assert.Equal(t, id, visitorId(newContext("10.0.0.1:1234", "203.0.113.9")))
+	// 经过本机反向代理时按转发的地址区分访客
+	assert.Equal(t, id, visitorId(newContext("127.0.0.1:1234", "10.0.0.1")))
+}
+
+func TestIsPrefetch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/blog/public/1", nil)
+	assert.False(t, isPrefetch(ctx))
+	ctx.Request.Header.Set("Sec-Purpose", "prefetch;prerender")
+	assert.True(t, isPrefetch(ctx))
+}
@@ -0,0 +1,16 @@
+package job
+
+import "go.uber.org/zap"
+
+// runRecovered 执行一次任务并捕获其中的 panic。数据库连接失败时 GetBlogDBConnection 会 panic,
+// 后台 goroutine 中未捕获的 panic 会拖垮整个进程; 定时任务每次执行都单独捕获, 下次到期时继续重试。
+// 任务正常结束时返回 true
+func runRecovered(name string, task func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			zap.L().Error("job panicked", zap.String("job", name), zap.Any("panic", r))
+		}
+	}()
+	task()
+	return true
+}
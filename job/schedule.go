@@ -0,0 +1,39 @@
+package job
+
+import (
+	"myblog/database"
+	"myblog/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StartPublishScheduler 启动后台任务, 定期执行到期的定时发布/取消发布计划。
+// 每个计划在数据库中被原子地认领, 多个副本同时执行也只会触发一次。
+func StartPublishScheduler() {
+	config := util.CreateConfig("blog")
+	interval := config.GetDuration("schedule.interval")
+	if interval <= 0 {
+		zap.L().Warn("publish scheduler disabled", zap.Duration("interval", interval))
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runRecovered("publish scheduler", runDueSchedules)
+		}
+	}()
+}
+
+func runDueSchedules() {
+	count, err := database.RunDueSchedules(time.Now())
+	if err != nil {
+		zap.L().Error("run due schedules failed", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		zap.L().Info("run due schedules", zap.Int("count", count))
+	}
+}
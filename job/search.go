@@ -0,0 +1,34 @@
+package job
+
+import (
+	"myblog/database"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	fulltextRetryMin = time.Minute
+	fulltextRetryMax = time.Hour
+)
+
+// StartFulltextIndex 启动时在后台建立搜索用的全文索引, 失败时间隔逐渐加长地重试直到成功。
+// 建好之前搜索使用 LIKE 查询; 多个副本同时执行时, 后执行的发现索引已存在直接跳过
+func StartFulltextIndex() {
+	go func() {
+		retry := fulltextRetryMin
+		for {
+			var err error
+			ok := runRecovered("fulltext index", func() { err = database.EnsureFulltextIndex() })
+			if ok && err == nil {
+				zap.L().Info("fulltext index ready")
+				return
+			}
+			if err != nil {
+				zap.L().Error("create fulltext index failed, search falls back to LIKE", zap.Duration("retry", retry), zap.Error(err))
+			}
+			time.Sleep(retry)
+			retry = min(retry*2, fulltextRetryMax)
+		}
+	}()
+}
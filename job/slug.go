@@ -0,0 +1,28 @@
+package job
+
+import (
+	"myblog/database"
+
+	"go.uber.org/zap"
+)
+
+// StartSlugBackfill 启动时在后台为还没有别名的博客补上别名, 只执行一次。
+// 多个副本同时执行时, 已有别名的博客会被跳过, 同名冲突由唯一键重试处理
+func StartSlugBackfill() {
+	go func() {
+		// 数据库连接失败时 GetBlogDBConnection 会 panic, 不能让启动时的一次性任务拖垮整个进程
+		defer func() {
+			if r := recover(); r != nil {
+				zap.L().Error("backfill blog slugs panicked", zap.Any("panic", r))
+			}
+		}()
+		count, err := database.BackfillBlogSlugs()
+		if err != nil {
+			zap.L().Error("backfill blog slugs failed", zap.Error(err))
+			return
+		}
+		if count > 0 {
+			zap.L().Info("backfill blog slugs", zap.Int("count", count))
+		}
+	}()
+}
@@ -0,0 +1,40 @@
+package job
+
+import (
+	"myblog/database"
+	"myblog/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StartTrashPurge 启动后台任务, 定期彻底删除回收站中超过保留期的博客。
+// 清理操作是幂等的, 多个副本同时执行不会产生错误的结果。
+func StartTrashPurge() {
+	config := util.CreateConfig("blog")
+	retention := config.GetDuration("trash.retention")
+	interval := config.GetDuration("trash.purge_interval")
+	if retention <= 0 || interval <= 0 {
+		zap.L().Warn("trash purge disabled", zap.Duration("retention", retention), zap.Duration("interval", interval))
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runRecovered("trash purge", func() { purgeTrashedBlogs(retention) })
+		}
+	}()
+}
+
+func purgeTrashedBlogs(retention time.Duration) {
+	count, err := database.PurgeTrashedBlogs(retention)
+	if err != nil {
+		zap.L().Error("purge trashed blogs failed", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		zap.L().Info("purge trashed blogs", zap.Int("count", count))
+	}
+}
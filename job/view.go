@@ -0,0 +1,39 @@
+package job
+
+import (
+	"myblog/database"
+	"myblog/util"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StartViewFlush 启动后台任务, 定期把 Redis 中累计的阅读量写入 MySQL。
+// 写入的是每天的累计值, 多个副本同时执行不会重复计数。
+func StartViewFlush() {
+	config := util.CreateConfig("blog")
+	interval := config.GetDuration("view.flush_interval")
+	if interval <= 0 {
+		zap.L().Warn("view flush disabled", zap.Duration("interval", interval))
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runRecovered("view flush", flushBlogViews)
+		}
+	}()
+}
+
+func flushBlogViews() {
+	count, err := database.FlushBlogViews()
+	if err != nil {
+		zap.L().Error("flush blog views failed", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		zap.L().Debug("flush blog views", zap.Int("count", count))
+	}
+}
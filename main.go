@@ -3,6 +3,7 @@ package main
 import (
 	"myblog/handler"
 	"myblog/handler/middleware"
+	"myblog/job"
 	"myblog/util"
 
 	"github.com/gin-gonic/gin"
@@ -17,12 +18,20 @@ func NewHtml() gin.HandlerFunc {
 
 func InitMain() {
 	util.InitLogger("log")
+	job.StartTrashPurge()
+	job.StartPublishScheduler()
+	job.StartViewFlush()
+	job.StartSlugBackfill()
+	job.StartFulltextIndex()
 }
 
-func main() {
-	InitMain()
-
+// NewRouter 创建路由并注册全部 handler
+func NewRouter() *gin.Engine {
 	router := gin.Default()
+	// 默认信任所有代理, ClientIP 可以被 X-Forwarded-For 伪造, 限流和访客统计都依赖它
+	if err := router.SetTrustedProxies(handler.TrustedProxies()); err != nil {
+		panic("site.trusted_proxies 配置错误: " + err.Error())
+	}
 	router.Use(middleware.Metric())
 
 	router.GET("/metrics", func(ctx *gin.Context) {
@@ -41,30 +50,102 @@ func main() {
 		"views/blog.html",
 		"views/public_blog_list.html",
 		"views/blog_public.html",
+		"views/bookmarks.html",
+		"views/openapi.html",
+		"views/error.html",
 	)
 
 	router.GET("/login", func(ctx *gin.Context) {
 		ctx.HTML(200, "login.html", nil)
 	})
 	router.GET("/", handler.NewHome())
+	router.GET("/openapi.json", handler.NewOpenAPI(router.Routes))
+	router.GET("/openapi", handler.NewOpenAPIViewer())
 	router.POST("/login/submit", handler.NewLogin())
 	router.POST("/register/submit", handler.NewRegister())
 	router.POST("/token", handler.GetAuthToken)
 
+	router.GET("/p/:slug", handler.NewPublicBlogBySlug())
+	router.GET("/sitemap.xml", handler.NewSitemap())
+	router.GET("/robots.txt", handler.NewRobots())
+	router.GET("/feed.xml", handler.NewPublicFeed("rss"))
+	router.GET("/feed.atom", handler.NewPublicFeed("atom"))
+	router.GET("/user/:uid/feed.xml", handler.NewUserFeed())
+
+	router.POST("/upload", middleware.Auth(), handler.NewUpload())
+	router.GET("/upload/:name", handler.NewUploadFile())
+	router.GET("/uploads", middleware.Auth(), handler.NewUploadList())
+
+	router.GET("/bookmarks", handler.NewBookmarkPage())
+	router.GET("/bookmarks/list", middleware.Auth(), handler.NewBookmarkList())
+
 	router.GET("/blog/belong", handler.BlogBelong)
+	router.GET("/blog/trash", middleware.Auth(), handler.NewBlogTrash())
 	router.GET("/blog/public", handler.NewPublicBlogList())
 
 	router.GET("/blog/list/:uid", handler.NewBlogList())
 	router.GET("/blog/:bid", handler.NewBlogDetail())
+	router.GET("/blog/public/search", handler.NewPublicBlogSearch())
+	router.GET("/blog/public/tag/:tag", handler.NewPublicBlogTag())
 	router.GET("/blog/public/:bid", handler.NewPublicBlogDetail())
+	router.GET("/blog/public/:bid/views", handler.NewPublicBlogViews())
+	router.GET("/blog/public/:bid/reactions", handler.NewPublicBlogReactions())
+	router.PUT("/blog/public/:bid/reactions/:kind", middleware.Auth(), handler.NewPublicBlogReactionSet(true))
+	router.DELETE("/blog/public/:bid/reactions/:kind", middleware.Auth(), handler.NewPublicBlogReactionSet(false))
+	router.GET("/blog/public/:bid/bookmark", middleware.Auth(), handler.NewBookmarkStatus())
+	router.PUT("/blog/public/:bid/bookmark", middleware.Auth(), handler.NewBookmarkSet(true))
+	router.DELETE("/blog/public/:bid/bookmark", middleware.Auth(), handler.NewBookmarkSet(false))
 	router.GET("/blog/public/:bid/comments", handler.NewPublicBlogComments())
 	router.POST("/blog/public/:bid/comments", middleware.Auth(), handler.NewPublicBlogCommentCreate())
+	router.PUT("/blog/public/:bid/comments/:cid", middleware.Auth(), handler.NewPublicBlogCommentUpdate())
 	router.DELETE("/blog/public/:bid/comments/:cid", middleware.Auth(), handler.NewPublicBlogCommentDelete())
+	router.GET("/blog/public/:bid/comments/mode", handler.NewPublicBlogCommentMode())
 
 	router.POST("/blog/create", middleware.Auth(), handler.NewBlogCreate())
 	router.POST("/blog/update", middleware.Auth(), handler.NewBlogUpdate())
 	router.POST("/blog/publish", middleware.Auth(), handler.NewBlogPublish())
 	router.POST("/blog/unpublish", middleware.Auth(), handler.NewBlogUnpublish())
 
-	router.Run(":5678")
+	router.GET("/blog/:bid/revisions", middleware.Auth(), handler.NewBlogRevisions())
+	router.GET("/blog/:bid/revisions/diff", middleware.Auth(), handler.NewBlogRevisionDiff())
+	router.POST("/blog/:bid/revisions/:rid/restore", middleware.Auth(), handler.NewBlogRevisionRestore())
+
+	router.GET("/blog/:bid/comments", middleware.Auth(), handler.NewBlogComments())
+	router.PUT("/blog/:bid/comments/:cid/status", middleware.Auth(), handler.NewBlogCommentStatus())
+	router.GET("/blog/:bid/comments/mode", middleware.Auth(), handler.NewBlogCommentMode())
+	router.PUT("/blog/:bid/comments/mode", middleware.Auth(), handler.NewBlogCommentModeSet())
+
+	router.GET("/blog/:bid/draft", middleware.Auth(), handler.NewBlogDraft())
+	router.POST("/blog/:bid/draft", middleware.Auth(), handler.NewBlogDraftSave())
+	router.DELETE("/blog/:bid/draft", middleware.Auth(), handler.NewBlogDraftDelete())
+
+	router.DELETE("/blog/:bid", middleware.Auth(), handler.NewBlogDelete())
+	router.POST("/blog/:bid/restore", middleware.Auth(), handler.NewBlogRestore())
+
+	api := router.Group("/api/v1")
+	api.POST("/auth/login", handler.NewApiLogin())
+	api.POST("/auth/register", handler.NewApiRegister())
+	api.POST("/auth/token", handler.NewApiToken())
+
+	api.GET("/blogs", middleware.Auth(), handler.NewApiBlogs())
+	api.POST("/blogs", middleware.Auth(), handler.NewApiBlogCreate())
+	api.GET("/blogs/:bid", middleware.Auth(), handler.NewApiBlog())
+	api.PUT("/blogs/:bid", middleware.Auth(), handler.NewApiBlogUpdate())
+	api.DELETE("/blogs/:bid", middleware.Auth(), handler.NewApiBlogDelete())
+	api.POST("/blogs/:bid/publish", middleware.Auth(), handler.NewApiBlogPublish())
+	api.DELETE("/blogs/:bid/publish", middleware.Auth(), handler.NewApiBlogUnpublish())
+
+	api.GET("/public/blogs", handler.NewApiPublicBlogs())
+	api.GET("/public/blogs/:bid", handler.NewApiPublicBlog())
+	api.GET("/public/blogs/:bid/comments", handler.NewApiPublicBlogComments())
+	api.POST("/public/blogs/:bid/comments", middleware.Auth(), handler.NewApiPublicBlogCommentCreate())
+	api.PUT("/public/blogs/:bid/comments/:cid", middleware.Auth(), handler.NewApiPublicBlogCommentUpdate())
+	api.DELETE("/public/blogs/:bid/comments/:cid", middleware.Auth(), handler.NewApiPublicBlogCommentDelete())
+
+	return router
+}
+
+func main() {
+	InitMain()
+	NewRouter().Run(":5678")
 }
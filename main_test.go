@@ -0,0 +1,65 @@
+package main
+
+import (
+	"myblog/handler"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOpenAPICoversAllRoutes 新增路由没有在 handler/openapi.go 中登记时失败
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := NewRouter()
+	assert.Empty(t, handler.OpenAPIMissingRoutes(router.Routes()), "register these routes in openapiOperations")
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	spec := struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}{}
+	assert.NoError(t, sonic.Unmarshal(writer.Body.Bytes(), &spec))
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+
+	for _, route := range router.Routes() {
+		if route.Method == http.MethodHead || strings.HasPrefix(route.Handler, "github.com/gin-gonic/gin.") {
+			continue
+		}
+		segments := strings.Split(route.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = "{" + segment[1:] + "}"
+			}
+		}
+		path := strings.Join(segments, "/")
+		assert.Contains(t, spec.Paths[path], strings.ToLower(route.Method), "%s %s missing from /openapi.json", route.Method, route.Path)
+	}
+}
+
+// TestRouterClientIPIgnoresUntrustedForwardedFor 评论限流按 ClientIP 计数, 不可信的来源不能靠 X-Forwarded-For 换 IP
+func TestRouterClientIPIgnoresUntrustedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := NewRouter()
+	router.GET("/test/client-ip", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.ClientIP())
+	})
+
+	clientIP := func(remoteAddr string) string {
+		writer := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/test/client-ip", nil)
+		request.RemoteAddr = remoteAddr
+		request.Header.Set("X-Forwarded-For", "203.0.113.9")
+		router.ServeHTTP(writer, request)
+		return writer.Body.String()
+	}
+	assert.Equal(t, "192.0.2.1", clientIP("192.0.2.1:1234"))
+	// config/blog.yaml 信任本机的反向代理
+	assert.Equal(t, "203.0.113.9", clientIP("127.0.0.1:1234"))
+}
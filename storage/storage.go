@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"myblog/global"
+	"myblog/util"
+	"path/filepath"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotExist   = errors.New("object not exist")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage 上传文件的存储后端。key 由调用方生成, 只包含小写字母、数字、'/' 和 '.'
+type Storage interface {
+	// Put 写入对象, key 已存在时覆盖
+	Put(key string, reader io.Reader, size int64, contentType string) error
+	// Get 读取对象, 调用方负责关闭; 对象不存在时返回 ErrNotExist
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	Delete(key string) error
+}
+
+var (
+	defaultStorage Storage
+	storageOnce    sync.Once
+)
+
+// Default 按 config/blog.yaml 的 upload.backend 创建存储后端, 进程内只创建一次
+func Default() Storage {
+	storageOnce.Do(func() {
+		storage, err := New(util.CreateConfig("blog").GetString("upload.backend"))
+		if err != nil {
+			panic(err)
+		}
+		defaultStorage = storage
+	})
+	return defaultStorage
+}
+
+// SetDefault 替换默认存储后端, 供测试使用
+func SetDefault(storage Storage) {
+	storageOnce.Do(func() {})
+	defaultStorage = storage
+}
+
+func New(backend string) (Storage, error) {
+	config := util.CreateConfig("blog")
+	switch backend {
+	case "", "local":
+		dir := config.GetString("upload.local.dir")
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(global.ProjectRootPath, dir)
+		}
+		zap.L().Info("use local storage", zap.String("dir", dir))
+		return NewLocalStorage(dir), nil
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"myblog/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStorage(t *testing.T, store storage.Storage) {
+	key := "ab/cd/abcd.png"
+	exists, err := store.Exists(key)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = store.Get(key)
+	assert.ErrorIs(t, err, storage.ErrNotExist)
+
+	assert.NoError(t, store.Put(key, strings.NewReader("hello"), 5, "image/png"))
+	exists, err = store.Exists(key)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	reader, err := store.Get(key)
+	if assert.NoError(t, err) {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		assert.Equal(t, "hello", string(data))
+	}
+
+	assert.NoError(t, store.Delete(key))
+	assert.NoError(t, store.Delete(key), "deleting a missing object is not an error")
+	exists, _ = store.Exists(key)
+	assert.False(t, exists)
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, storage.NewLocalStorage(t.TempDir()))
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, storage.NewMemoryStorage())
+}
+
+func TestLocalStorageRejectsPathTraversal(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	for _, key := range []string{"../secret", "/etc/passwd", "", `a\..\b`} {
+		assert.ErrorIs(t, store.Put(key, strings.NewReader("x"), 1, "text/plain"), storage.ErrInvalidKey, key)
+	}
+}
@@ -0,0 +1,198 @@
+package util
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffLine struct {
+	Op      string `json:"op"`       // equal / insert / delete
+	OldLine int    `json:"old_line"` // 在旧文本中的行号(从1开始), insert 行为0
+	NewLine int    `json:"new_line"` // 在新文本中的行号(从1开始), delete 行为0
+	Text    string `json:"text"`
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// MaxDiffLines 参与比较的文本最多的行数, 超过时 LineDiff 返回 ErrDiffTooLarge。
+// 完全不同的两段文本耗时与 (n+m)·D 成正比, 需要限制输入规模
+const MaxDiffLines = 10000
+
+var ErrDiffTooLarge = errors.New("text too large to diff")
+
+// LineDiff 按行比较两段文本, 使用 Myers 差分算法的线性空间版本得到最短编辑脚本:
+// 每次找出编辑路径的中间蛇形段再对两侧递归, 内存与行数成正比
+func LineDiff(oldText, newText string) ([]DiffLine, error) {
+	oldLines, newLines := splitLines(oldText), splitLines(newText)
+	if len(oldLines) > MaxDiffLines || len(newLines) > MaxDiffLines {
+		return nil, ErrDiffTooLarge
+	}
+
+	// 行内容换成整数编号, 比较时不必反复比较字符串
+	ids := make(map[string]int, len(oldLines)+len(newLines))
+	lineIds := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+
+	size := 2*((len(oldLines)+len(newLines)+1)/2) + 3
+	d := &differ{
+		oldLines: oldLines,
+		newLines: newLines,
+		a:        lineIds(oldLines),
+		b:        lineIds(newLines),
+		vf:       make([]int, size),
+		vb:       make([]int, size),
+		lines:    make([]DiffLine, 0, len(oldLines)+len(newLines)),
+	}
+	d.compare(0, len(oldLines), 0, len(newLines))
+
+	// 连续修改的一段中先列出删除的行, 再列出插入的行
+	for start := 0; start < len(d.lines); {
+		end := start
+		for end < len(d.lines) && d.lines[end].Op != DiffEqual {
+			end++
+		}
+		if end == start {
+			start++
+			continue
+		}
+		slices.SortStableFunc(d.lines[start:end], func(x, y DiffLine) int {
+			return cmp.Compare(diffOpOrder(x.Op), diffOpOrder(y.Op))
+		})
+		start = end
+	}
+	return d.lines, nil
+}
+
+func diffOpOrder(op string) int {
+	if op == DiffDelete {
+		return 0
+	}
+	return 1
+}
+
+type differ struct {
+	oldLines, newLines []string
+	a, b               []int
+	vf, vb             []int // 前向和后向搜索在各条对角线上到达的最远位置, 各次查找中间段时复用
+	lines              []DiffLine
+}
+
+func (d *differ) equal(x, y int) {
+	d.lines = append(d.lines, DiffLine{Op: DiffEqual, OldLine: x + 1, NewLine: y + 1, Text: d.oldLines[x]})
+}
+
+// compare 比较 a[aLo:aHi] 和 b[bLo:bHi], 按顺序追加结果
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.equal(aLo, bLo)
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-1-suffix] == d.b[bHi-1-suffix] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			d.lines = append(d.lines, DiffLine{Op: DiffInsert, NewLine: y + 1, Text: d.newLines[y]})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			d.lines = append(d.lines, DiffLine{Op: DiffDelete, OldLine: x + 1, Text: d.oldLines[x]})
+		}
+	default:
+		// 去掉首尾相同的行后两侧都不为空, 编辑距离至少为 2, 中间段两侧的子问题都更小
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.equal(x, y)
+		}
+		d.compare(u, aHi, v, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.equal(aHi+i, bHi+i)
+	}
+}
+
+// middleSnake 从两端同时搜索, 返回最短编辑路径中间的蛇形段 (x, y) 到 (u, v)
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+	offset := max + 1
+	vf, vb := d.vf, d.vb
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for step := 0; step <= max; step++ {
+		// 前向搜索, k 为 x-y
+		for k := -step; k <= step; k += 2 {
+			var x0 int
+			if k == -step || (k != step && vf[offset+k-1] < vf[offset+k+1]) {
+				x0 = vf[offset+k+1] // 向下走: 插入
+			} else {
+				x0 = vf[offset+k-1] + 1 // 向右走: 删除
+			}
+			y0 := x0 - k
+			x1, y1 := x0, y0
+			for x1 < n && y1 < m && d.a[aLo+x1] == d.b[bLo+y1] {
+				x1++
+				y1++
+			}
+			vf[offset+k] = x1
+			// 后向搜索的对角线 delta-k 上一步已经到达或越过这里, 两条路径相遇
+			if odd && k >= delta-(step-1) && k <= delta+(step-1) && x1+vb[offset+delta-k] >= n {
+				return aLo + x0, bLo + y0, aLo + x1, bLo + y1
+			}
+		}
+		// 后向搜索, 在倒序的文本上进行, 对角线 k 对应前向的 delta-k
+		for k := -step; k <= step; k += 2 {
+			var x0 int
+			if k == -step || (k != step && vb[offset+k-1] < vb[offset+k+1]) {
+				x0 = vb[offset+k+1]
+			} else {
+				x0 = vb[offset+k-1] + 1
+			}
+			y0 := x0 - k
+			x1, y1 := x0, y0
+			for x1 < n && y1 < m && d.a[aHi-1-x1] == d.b[bHi-1-y1] {
+				x1++
+				y1++
+			}
+			vb[offset+k] = x1
+			if !odd && delta-k >= -step && delta-k <= step && x1+vf[offset+delta-k] >= n {
+				return aHi - x1, bHi - y1, aHi - x0, bHi - y0
+			}
+		}
+	}
+	// 两侧都不为空时一定能在 max 步内相遇
+	panic("util: middle snake not found")
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"html/template"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// SearchTerms 把查询串拆成用于高亮的词: 按空白切分, 较长的中日韩词额外拆成二元组,
+// 与 MySQL ngram 分词(ngram_token_size=2)的匹配方式保持一致。结果按长度倒序, 长词优先匹配
+func SearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0, 4)
+	add := func(term string) {
+		if len(term) > 0 && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		add(field)
+		runes := []rune(field)
+		if len(runes) <= 2 {
+			continue
+		}
+		for i := 0; i+2 <= len(runes); i++ {
+			if isCJK(runes[i]) && isCJK(runes[i+1]) {
+				add(string(runes[i : i+2]))
+			}
+		}
+	}
+	sort.SliceStable(terms, func(i, j int) bool {
+		return len([]rune(terms[i])) > len([]rune(terms[j]))
+	})
+	return terms
+}
+
+type runeRange struct {
+	start, end int
+}
+
+// findMatches 在 text 中查找所有 terms 的出现位置(不区分大小写), 已被长词覆盖的位置不再匹配短词
+func findMatches(text []rune, terms []string) []runeRange {
+	lower := []rune(strings.ToLower(string(text)))
+	if len(lower) != len(text) { // 极少数字符小写后长度变化, 退回逐字比较原文
+		lower = text
+	}
+	covered := make([]bool, len(text))
+	var matches []runeRange
+	for _, term := range terms {
+		pattern := []rune(term)
+		for i := 0; i+len(pattern) <= len(lower); i++ {
+			if covered[i] || string(lower[i:i+len(pattern)]) != term {
+				continue
+			}
+			free := true
+			for j := i; j < i+len(pattern); j++ {
+				if covered[j] {
+					free = false
+					break
+				}
+			}
+			if !free {
+				continue
+			}
+			for j := i; j < i+len(pattern); j++ {
+				covered[j] = true
+			}
+			matches = append(matches, runeRange{i, i + len(pattern)})
+			i += len(pattern) - 1
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	return matches
+}
+
+func markRanges(text []rune, matches []runeRange, from, to int) string {
+	var b strings.Builder
+	pos := from
+	for _, m := range matches {
+		if m.end <= from || m.start >= to {
+			continue
+		}
+		start, end := max(m.start, from), min(m.end, to)
+		b.WriteString(template.HTMLEscapeString(string(text[pos:start])))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(string(text[start:end])))
+		b.WriteString("</mark>")
+		pos = end
+	}
+	b.WriteString(template.HTMLEscapeString(string(text[pos:to])))
+	return b.String()
+}
+
+// Highlight 对整段文本做 HTML 转义并用 <mark> 标出命中的词
+func Highlight(text string, terms []string) template.HTML {
+	runes := []rune(text)
+	return template.HTML(markRanges(runes, findMatches(runes, terms), 0, len(runes)))
+}
+
+// Snippet 截取 text 中命中最早的词附近约 width 个字符并高亮, 没有命中时返回开头部分
+func Snippet(text string, terms []string, width int) template.HTML {
+	runes := []rune(text)
+	matches := findMatches(runes, terms)
+
+	from := 0
+	if len(matches) > 0 {
+		from = max(0, matches[0].start-width/4) // 命中词前保留少量上下文
+	}
+	to := min(len(runes), from+width)
+	if to-from < width {
+		from = max(0, to-width)
+	}
+
+	snippet := markRanges(runes, matches, from, to)
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet += "…"
+	}
+	return template.HTML(snippet)
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"bytes"
+	"container/list"
+	"html"
+	"html/template"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"go.uber.org/zap"
+)
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM), // CommonMark + GFM 表格/删除线/任务列表/自动链接
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+	markdownPolicy = newMarkdownPolicy()
+	textPolicy     = bluemonday.StrictPolicy()
+	markdownCache  = &renderCache{entries: make(map[int]*list.Element), order: list.New()}
+)
+
+// MarkdownCacheSize 渲染缓存最多保存的文章数, 超过时淘汰最久未使用的
+const MarkdownCacheSize = 1000
+
+type markdownCacheEntry struct {
+	id         int
+	updateTime time.Time
+	html       template.HTML
+}
+
+// renderCache 按 id 保存渲染结果的 LRU 缓存, 已删除或不再公开的文章不再被访问, 最终会被淘汰
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[int]*list.Element // value: *markdownCacheEntry
+	order   *list.List            // 最近使用的在前
+}
+
+func (c *renderCache) get(id int, updateTime time.Time) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[id]
+	if !ok {
+		return "", false
+	}
+	entry := element.Value.(*markdownCacheEntry)
+	if !entry.updateTime.Equal(updateTime) {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return entry.html, true
+}
+
+func (c *renderCache) put(id int, updateTime time.Time, html template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[id]; ok {
+		element.Value = &markdownCacheEntry{id: id, updateTime: updateTime, html: html}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[id] = c.order.PushFront(&markdownCacheEntry{id: id, updateTime: updateTime, html: html})
+	for c.order.Len() > MarkdownCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*markdownCacheEntry).id)
+	}
+}
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("id").OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code") // 保留 language-xxx 供前端高亮
+	policy.AllowAttrs("type", "checked", "disabled").OnElements("input")                    // GFM 任务列表
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}
+
+// RenderMarkdown 把 Markdown 渲染成经过 XSS 过滤的 HTML
+func RenderMarkdown(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		zap.L().Error("render markdown failed", zap.Error(err))
+		return template.HTML(template.HTMLEscapeString(source))
+	}
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes()))
+}
+
+// RenderMarkdownCached 以 id + updateTime 为版本缓存渲染结果, updateTime 变化后重新渲染。
+// 最多缓存 MarkdownCacheSize 篇, 超过时淘汰最久未使用的
+func RenderMarkdownCached(id int, updateTime time.Time, source string) template.HTML {
+	if html, ok := markdownCache.get(id, updateTime); ok {
+		return html
+	}
+	html := RenderMarkdown(source)
+	markdownCache.put(id, updateTime, html)
+	return html
+}
+
+// MarkdownToText 把 Markdown 转成去掉所有标记的纯文本, 连续空白折叠为一个空格, 用于摘要与搜索片段
+func MarkdownToText(source string) string {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return strings.Join(strings.Fields(source), " ")
+	}
+	// 块级标签之间补空格, 避免相邻段落的文字粘在一起
+	block := strings.NewReplacer("</p>", "</p> ", "</li>", "</li> ", "</h1>", "</h1> ", "</h2>", "</h2> ",
+		"</h3>", "</h3> ", "</h4>", "</h4> ", "</td>", "</td> ", "</th>", "</th> ", "<br>", " ", "<br />", " ")
+	text := html.UnescapeString(textPolicy.Sanitize(block.Replace(buf.String())))
+	return strings.Join(strings.Fields(text), " ")
+}
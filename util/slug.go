@@ -0,0 +1,55 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+const (
+	maxSlugLength = 64
+	DefaultSlug   = "post" // 标题中没有任何可用字符时使用
+)
+
+var pinyinArgs = pinyin.NewArgs()
+
+// Slugify 把标题转换成只含小写字母、数字和 '-' 的 URL 片段, 汉字按拼音音译。
+// 例如 "Go 泛型入门!" 转换为 "go-fan-xing-ru-men"
+func Slugify(title string) string {
+	var b strings.Builder
+	dash := false // 是否需要在下一个单词前补 '-'
+	writeWord := func(word string) {
+		if dash && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(word)
+		dash = false
+	}
+	for _, r := range title {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			writeWord(string(unicode.ToLower(r)))
+		case unicode.Is(unicode.Han, r):
+			if py := pinyin.SinglePinyin(r, pinyinArgs); len(py) > 0 {
+				dash = true
+				writeWord(py[0])
+			}
+			dash = true
+		default:
+			dash = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLength {
+		slug = slug[:maxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i] // 不截断半个单词
+		}
+	}
+	if len(slug) == 0 {
+		return DefaultSlug
+	}
+	return slug
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"myblog/util"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineDiff(t *testing.T) {
+	t.Run("identical", func(t *testing.T) {
+		lines, err := util.LineDiff("a\nb", "a\nb")
+		assert.NoError(t, err)
+		assert.Equal(t, []util.DiffLine{
+			{Op: util.DiffEqual, OldLine: 1, NewLine: 1, Text: "a"},
+			{Op: util.DiffEqual, OldLine: 2, NewLine: 2, Text: "b"},
+		}, lines)
+	})
+
+	t.Run("both empty", func(t *testing.T) {
+		lines, err := util.LineDiff("", "")
+		assert.NoError(t, err)
+		assert.NoError(t, err)
+		assert.Empty(t, lines)
+	})
+
+	t.Run("insert and delete", func(t *testing.T) {
+		lines, err := util.LineDiff("a\nb\nc", "a\nc\nd")
+		assert.NoError(t, err)
+		assert.Equal(t, []util.DiffLine{
+			{Op: util.DiffEqual, OldLine: 1, NewLine: 1, Text: "a"},
+			{Op: util.DiffDelete, OldLine: 2, Text: "b"},
+			{Op: util.DiffEqual, OldLine: 3, NewLine: 2, Text: "c"},
+			{Op: util.DiffInsert, NewLine: 3, Text: "d"},
+		}, lines)
+	})
+
+	t.Run("from empty", func(t *testing.T) {
+		lines, err := util.LineDiff("", "x\ny")
+		assert.NoError(t, err)
+		assert.Equal(t, []util.DiffLine{
+			{Op: util.DiffInsert, NewLine: 1, Text: "x"},
+			{Op: util.DiffInsert, NewLine: 2, Text: "y"},
+		}, lines)
+	})
+
+	t.Run("crlf is normalized", func(t *testing.T) {
+		lines, err := util.LineDiff("a\r\nb\r\n", "a\nb")
+		assert.NoError(t, err)
+		for _, line := range lines {
+			assert.Equal(t, util.DiffEqual, line.Op)
+		}
+	})
+	t.Run("large and completely different", func(t *testing.T) {
+		oldLines := make([]string, 5000)
+		newLines := make([]string, 5000)
+		for i := range oldLines {
+			oldLines[i] = "old " + strconv.Itoa(i)
+			newLines[i] = "new " + strconv.Itoa(i)
+		}
+		lines, err := util.LineDiff(strings.Join(oldLines, "\n"), strings.Join(newLines, "\n"))
+		assert.NoError(t, err)
+		assert.Len(t, lines, 10000)
+		deleted, inserted := 0, 0
+		for _, line := range lines {
+			switch line.Op {
+			case util.DiffDelete:
+				deleted++
+				assert.Equal(t, "old "+strconv.Itoa(line.OldLine-1), line.Text)
+			case util.DiffInsert:
+				inserted++
+				assert.Equal(t, "new "+strconv.Itoa(line.NewLine-1), line.Text)
+			default:
+				t.Fatalf("unexpected op %q", line.Op)
+			}
+		}
+		assert.Equal(t, 5000, deleted)
+		assert.Equal(t, 5000, inserted)
+	})
+
+	t.Run("shared lines are kept", func(t *testing.T) {
+		lines, err := util.LineDiff("a\nx\nb\ny\nc", "a\nb\nz\nc")
+		assert.NoError(t, err)
+		assert.Equal(t, []util.DiffLine{
+			{Op: util.DiffEqual, OldLine: 1, NewLine: 1, Text: "a"},
+			{Op: util.DiffDelete, OldLine: 2, Text: "x"},
+			{Op: util.DiffEqual, OldLine: 3, NewLine: 2, Text: "b"},
+			{Op: util.DiffDelete, OldLine: 4, Text: "y"},
+			{Op: util.DiffInsert, NewLine: 3, Text: "z"},
+			{Op: util.DiffEqual, OldLine: 5, NewLine: 4, Text: "c"},
+		}, lines)
+	})
+
+	t.Run("too many lines", func(t *testing.T) {
+		text := strings.Repeat("line\n", util.MaxDiffLines+1)
+		_, err := util.LineDiff(text, "")
+		assert.ErrorIs(t, err, util.ErrDiffTooLarge)
+	})
+}
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"myblog/util"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTerms(t *testing.T) {
+	assert.Equal(t, []string{"golang"}, util.SearchTerms("  Golang "))
+	assert.Equal(t, []string{"分布式", "分布", "布式"}, util.SearchTerms("分布式"))
+	assert.Equal(t, []string{"redis", "缓存"}, util.SearchTerms("redis 缓存"))
+}
+
+func TestHighlight(t *testing.T) {
+	t.Run("case insensitive", func(t *testing.T) {
+		assert.Equal(t, "Learn <mark>Go</mark> now", string(util.Highlight("Learn Go now", []string{"go"})))
+	})
+
+	t.Run("escape html", func(t *testing.T) {
+		assert.Equal(t, "&lt;b&gt;<mark>go</mark>", string(util.Highlight("<b>go", []string{"go"})))
+	})
+
+	t.Run("longest term first", func(t *testing.T) {
+		html := string(util.Highlight("学习分布式系统", util.SearchTerms("分布式")))
+		assert.Equal(t, "学习<mark>分布式</mark>系统", html)
+	})
+
+	t.Run("cjk bigram fallback", func(t *testing.T) {
+		html := string(util.Highlight("布式存储", util.SearchTerms("分布式")))
+		assert.Equal(t, "<mark>布式</mark>存储", html)
+	})
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("前", 100) + "关键词" + strings.Repeat("后", 100)
+	snippet := string(util.Snippet(text, []string{"关键词"}, 40))
+	assert.True(t, strings.HasPrefix(snippet, "…"))
+	assert.True(t, strings.HasSuffix(snippet, "…"))
+	assert.Contains(t, snippet, "<mark>关键词</mark>")
+
+	short := string(util.Snippet("hello", []string{"none"}, 40))
+	assert.Equal(t, "hello", short)
+}
+
+func TestMarkdownToText(t *testing.T) {
+	assert.Equal(t, "标题 第一段 bold and code", util.MarkdownToText("# 标题\n\n第一段\n\n**bold** and `code`"))
+	assert.Equal(t, "a < b", util.MarkdownToText("a &lt; b"))
+}
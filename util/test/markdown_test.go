@@ -0,0 +1,60 @@
+package test
+
+import (
+	"myblog/util"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	datas := []struct {
+		name   string
+		source string
+		expect string
+	}{
+		{"heading", "# 标题", "<h1 id="},
+		{"fenced code", "```go\nfmt.Println(1)\n```", `<code class="language-go">`},
+		{"gfm table", "| a | b |\n| - | - |\n| 1 | 2 |", "<table>"},
+		{"link", "[home](https://example.com)", `rel="nofollow noopener"`},
+	}
+	for _, data := range datas {
+		t.Run(data.name, func(t *testing.T) {
+			assert.Contains(t, string(util.RenderMarkdown(data.source)), data.expect)
+		})
+	}
+}
+
+func TestRenderMarkdownSanitize(t *testing.T) {
+	html := string(util.RenderMarkdown("<script>alert(1)</script>\n\n[x](javascript:alert(1))\n\n<img src=x onerror=alert(1)>"))
+	assert.False(t, strings.Contains(html, "<script"), html)
+	assert.False(t, strings.Contains(html, "javascript:"), html)
+	assert.False(t, strings.Contains(html, "onerror"), html)
+}
+
+func TestRenderMarkdownCached(t *testing.T) {
+	updateTime := time.Now()
+	first := util.RenderMarkdownCached(-1, updateTime, "old")
+	assert.Contains(t, string(first), "old")
+
+	// update_time 未变化时直接命中缓存
+	cached := util.RenderMarkdownCached(-1, updateTime, "new")
+	assert.Equal(t, first, cached)
+
+	// update_time 变化后重新渲染
+	renewed := util.RenderMarkdownCached(-1, updateTime.Add(time.Second), "new")
+	assert.Contains(t, string(renewed), "new")
+}
+
+func TestRenderMarkdownCachedEviction(t *testing.T) {
+	updateTime := time.Now()
+	util.RenderMarkdownCached(-2, updateTime, "old")
+
+	// 超过容量后最久未使用的被淘汰, 同样的 update_time 也会重新渲染
+	for i := 0; i < util.MarkdownCacheSize; i++ {
+		util.RenderMarkdownCached(-1000-i, updateTime, "filler")
+	}
+	assert.Contains(t, string(util.RenderMarkdownCached(-2, updateTime, "new")), "new")
+}
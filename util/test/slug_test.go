@@ -0,0 +1,30 @@
+package test
+
+import (
+	"myblog/util"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	cases := map[string]string{
+		"Hello, World!":     "hello-world",
+		"Go 泛型入门":           "go-fan-xing-ru-men",
+		"  Redis缓存 -- 实践  ": "redis-huan-cun-shi-jian",
+		"C++ & Go 1.22":     "c-go-1-22",
+		"!!!":               util.DefaultSlug,
+		"":                  util.DefaultSlug,
+	}
+	for title, expect := range cases {
+		assert.Equal(t, expect, util.Slugify(title), title)
+	}
+}
+
+func TestSlugifyTruncate(t *testing.T) {
+	slug := util.Slugify(strings.Repeat("长标题", 30))
+	assert.LessOrEqual(t, len(slug), 64)
+	assert.False(t, strings.HasSuffix(slug, "-"))
+	assert.True(t, strings.HasPrefix(slug, "zhang-biao-ti-"))
+}
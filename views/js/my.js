@@ -31,4 +31,18 @@ function getCookie(name) {
         return arr[2];
     else
         return null;
-}
\ No newline at end of file
+}
+// 取出 ajax 失败响应中的错误说明, 接口返回 {"error": {"code": ..., "message": ...}}
+function get_error_message(result, fallback) {
+    var body = result.responseJSON;
+    if (body && body.error && body.error.message) {
+        return body.error.message;
+    }
+    return result.responseText || fallback || "";
+}
+
+// 取出 ajax 失败响应中的错误码
+function get_error_code(result) {
+    var body = result.responseJSON;
+    return body && body.error ? body.error.code : "";
+}